@@ -0,0 +1,65 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package btree
+
+import "github.com/ajwerner/btree/internal/abstract"
+
+// Allocator allocates and frees the nodes of the maps which use it. By
+// default, all maps of the same type share pooled nodes. An Allocator may be
+// shared by maps of different types, including those of the orderstat,
+// interval and bytekey packages, and is safe for concurrent use.
+type Allocator = abstract.Allocator
+
+// AllocatorStats counts the nodes handled by an Allocator. The number of
+// nodes currently in use is Allocations + Reuses - Frees.
+type AllocatorStats = abstract.AllocatorStats
+
+// Option configures a Map or Set.
+type Option = abstract.Option
+
+// WithAllocator configures a map to allocate its nodes with the provided
+// Allocator. Clones of the map share its Allocator.
+func WithAllocator(a *Allocator) Option {
+	return abstract.WithAllocator(a)
+}
+
+// WithOutOfLineValues configures whether a map stores each of its values in
+// a separate allocation rather than in its nodes. Storing values out of line
+// makes cloning and restructuring nodes cheaper for large values at the cost
+// of an allocation per value. By default, values are stored in the nodes.
+func WithOutOfLineValues(enabled bool) Option {
+	return abstract.WithOutOfLineValues(enabled)
+}
+
+// NewPooledAllocator returns an Allocator which recycles freed nodes through
+// its own sync.Pool.
+func NewPooledAllocator() *Allocator {
+	return abstract.NewPooledAllocator()
+}
+
+// NewHeapAllocator returns an Allocator which allocates every node from the
+// Go heap and leaves freed nodes to the garbage collector. It avoids the
+// churn of a pool at the cost of more garbage.
+func NewHeapAllocator() *Allocator {
+	return abstract.NewHeapAllocator()
+}
+
+// NewArenaAllocator returns an Allocator which allocates nodes in slabs and
+// keeps freed nodes for reuse. Calling Free on it releases all of its memory
+// at once, which allows a batch job to discard an entire tree without
+// visiting its nodes.
+func NewArenaAllocator() *Allocator {
+	return abstract.NewArenaAllocator()
+}
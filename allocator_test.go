@@ -0,0 +1,211 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package btree
+
+import (
+	"maps"
+	"math/rand"
+	"runtime"
+	"testing"
+)
+
+// largeValue is large enough to benefit from being stored out of line.
+type largeValue [64]int
+
+func makeLargeValue(i int) (v largeValue) {
+	for j := range v {
+		v[j] = i + j
+	}
+	return v
+}
+
+func TestLargeValues(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		m    Map[int, largeValue]
+	}{
+		{"btree", MakeOrderedMap[int, largeValue](WithOutOfLineValues(true))},
+		{"bplus", MakeOrderedBPlusMap[int, largeValue](WithOutOfLineValues(true))},
+		{"inline", MakeOrderedMap[int, largeValue]()},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rng := newRand(t)
+			const N = 5000
+			m := tc.m
+			ref := map[int]largeValue{}
+			type clone struct {
+				m   Map[int, largeValue]
+				ref map[int]largeValue
+			}
+			var clones []clone
+			for i := 0; i < 4*N; i++ {
+				k := rng.Intn(N)
+				if rng.Intn(3) == 0 {
+					_, v, found := m.Delete(k)
+					if rv, ok := ref[k]; ok != found || v != rv {
+						t.Fatalf("unexpected result deleting %d", k)
+					}
+					delete(ref, k)
+				} else {
+					_, v, replaced := m.Upsert(k, makeLargeValue(i))
+					if rv, ok := ref[k]; ok != replaced || v != rv {
+						t.Fatalf("unexpected result upserting %d", k)
+					}
+					ref[k] = makeLargeValue(i)
+				}
+				if i%N == 0 {
+					clones = append(clones, clone{m: m.Clone(), ref: maps.Clone(ref)})
+				}
+			}
+			checkMap(t, &m, ref)
+			it := m.MutatingIterator()
+			for it.First(); it.Valid(); it.Next() {
+				v := makeLargeValue(it.Value()[0] + 1)
+				it.SetValue(v)
+				ref[it.Cur()] = v
+			}
+			checkMap(t, &m, ref)
+			m.Compact(1)
+			checkMap(t, &m, ref)
+			for _, c := range clones {
+				checkMap(t, &c.m, c.ref)
+				c.m.Reset()
+			}
+		})
+	}
+}
+
+func TestOutOfLineValues(t *testing.T) {
+	m := MakeOrderedMap[int, int](WithOutOfLineValues(true))
+	for i := 0; i < 1000; i++ {
+		m.Upsert(i, i)
+	}
+	c := m.Clone()
+	for i := 0; i < 1000; i += 2 {
+		m.Upsert(i, -i)
+	}
+	for i := 0; i < 1000; i++ {
+		exp := i
+		if i%2 == 0 {
+			exp = -i
+		}
+		if v, _ := m.Get(i); v != exp {
+			t.Fatalf("expected %d for %d, got %d", exp, i, v)
+		}
+		if v, _ := c.Get(i); v != i {
+			t.Fatalf("clone: expected %d for %d, got %d", i, i, v)
+		}
+	}
+}
+
+func BenchmarkLargeValues(b *testing.B) {
+	const count = 1 << 14
+	m := MakeOrderedMap[int, largeValue](WithOutOfLineValues(true))
+	for _, k := range rand.Perm(count) {
+		m.Upsert(k, makeLargeValue(k))
+	}
+	b.Run("clone-upsert", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			c := m.Clone()
+			c.Upsert(i%count, largeValue{})
+			c.Reset()
+		}
+	})
+	b.Run("get", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			m.Get(i % count)
+		}
+	})
+}
+
+func TestAllocator(t *testing.T) {
+	inUse := func(s AllocatorStats) int64 { return s.Allocations + s.Reuses - s.Frees }
+	fill := func(a *Allocator) (Map[int, int], Map[int, int]) {
+		m := MakeOrderedMap[int, int](WithAllocator(a))
+		for i := 0; i < 10000; i++ {
+			m.Upsert(i, i)
+		}
+		c := m.Clone()
+		for i := 0; i < 10000; i += 3 {
+			c.Delete(i)
+		}
+		return m, c
+	}
+	for _, tc := range []struct {
+		name string
+		a    *Allocator
+		// mustReuse and mayReuse indicate whether freed nodes will or may be
+		// reused. A sync.Pool may drop freed nodes at any time.
+		mustReuse, mayReuse bool
+	}{
+		{"pooled", NewPooledAllocator(), false, true},
+		{"heap", NewHeapAllocator(), false, false},
+		{"arena", NewArenaAllocator(), true, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m, c := fill(tc.a)
+			if s := tc.a.Stats(); s.Allocations == 0 || inUse(s) == 0 {
+				t.Fatalf("expected nodes to be allocated, got %+v", s)
+			}
+			m.Reset()
+			c.Reset()
+			if s := tc.a.Stats(); inUse(s) != 0 {
+				t.Fatalf("expected all nodes to be freed, got %+v", s)
+			}
+			before := tc.a.Stats()
+			m, c = fill(tc.a)
+			if s := tc.a.Stats(); tc.mustReuse && s.Reuses == before.Reuses {
+				t.Fatalf("expected freed nodes to be reused, got %+v", s)
+			} else if !tc.mayReuse && s.Reuses != 0 {
+				t.Fatalf("expected no nodes to be reused, got %+v", s)
+			}
+			if m.Len() != 10000 || c.Len() != 6666 {
+				t.Fatalf("expected 10000 and 6666 entries, got %d and %d", m.Len(), c.Len())
+			}
+			tc.a.Free()
+			if tc.name != "arena" {
+				m.Reset()
+				c.Reset()
+			}
+			if s := tc.a.Stats(); inUse(s) != 0 {
+				t.Fatalf("expected all nodes to be freed, got %+v", s)
+			}
+		})
+	}
+}
+
+func BenchmarkGC(b *testing.B) {
+	const count = 1 << 21
+	for _, tc := range []struct {
+		name string
+		a    *Allocator
+	}{
+		{"pooled", NewPooledAllocator()},
+		{"arena", NewArenaAllocator()},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			m := MakeOrderedMap[int, int](WithAllocator(tc.a))
+			for i := 0; i < count; i++ {
+				m.Upsert(i, i)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				runtime.GC()
+			}
+			b.StopTimer()
+			m.Reset()
+		})
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package btree
+
+// BiMap is an ordered one-to-one mapping between keys of type K and values
+// of type V. It can be looked up and iterated in order from either side.
+// Each key is associated with at most one value and each value with at most
+// one key.
+type BiMap[K, V any] struct {
+	fwd Map[K, V]
+	rev Map[V, K]
+}
+
+// MakeBiMap constructs a new BiMap with the provided comparison functions
+// for keys and values.
+func MakeBiMap[K, V any](
+	cmpK func(K, K) int, cmpV func(V, V) int, opts ...Option,
+) BiMap[K, V] {
+	return BiMap[K, V]{
+		fwd: MakeMap[K, V](cmpK, opts...),
+		rev: MakeMap[V, K](cmpV, opts...),
+	}
+}
+
+// Clone clones the BiMap, lazily. It does so in constant time.
+func (b *BiMap[K, V]) Clone() BiMap[K, V] {
+	return BiMap[K, V]{fwd: b.fwd.Clone(), rev: b.rev.Clone()}
+}
+
+// Reset removes all items from the BiMap. In doing so, it allows memory
+// held by the BiMap to be recycled.
+func (b *BiMap[K, V]) Reset() {
+	b.fwd.Reset()
+	b.rev.Reset()
+}
+
+// Len returns the number of pairs in the BiMap.
+func (b *BiMap[K, V]) Len() int {
+	return b.fwd.Len()
+}
+
+// Upsert associates k with v. Any existing association of k with another
+// value or of v with another key is removed. It returns the value which was
+// previously associated with k and the key which was previously associated
+// with v, if any.
+func (b *BiMap[K, V]) Upsert(k K, v V) (prevV V, hadK bool, prevK K, hadV bool) {
+	prevV, hadK = b.fwd.Get(k)
+	prevK, hadV = b.rev.Get(v)
+	if hadK {
+		b.rev.Delete(prevV)
+	}
+	if hadV {
+		b.fwd.Delete(prevK)
+	}
+	b.fwd.Upsert(k, v)
+	b.rev.Upsert(v, k)
+	return prevV, hadK, prevK, hadV
+}
+
+// Get returns the value associated with the key, if it exists.
+func (b *BiMap[K, V]) Get(k K) (v V, ok bool) {
+	return b.fwd.Get(k)
+}
+
+// GetKey returns the key associated with the value, if it exists.
+func (b *BiMap[K, V]) GetKey(v V) (k K, ok bool) {
+	return b.rev.Get(v)
+}
+
+// Delete removes the key and its value. It returns the removed value if the
+// key existed.
+func (b *BiMap[K, V]) Delete(k K) (v V, found bool) {
+	if _, v, found = b.fwd.Delete(k); found {
+		b.rev.Delete(v)
+	}
+	return v, found
+}
+
+// DeleteValue removes the value and its key. It returns the removed key if
+// the value existed.
+func (b *BiMap[K, V]) DeleteValue(v V) (k K, found bool) {
+	if _, k, found = b.rev.Delete(v); found {
+		b.fwd.Delete(k)
+	}
+	return k, found
+}
+
+// Iterator constructs an iterator over the pairs of the BiMap in key order.
+func (b *BiMap[K, V]) Iterator() MapIterator[K, V] {
+	return b.fwd.Iterator()
+}
+
+// InverseIterator constructs an iterator over the pairs of the BiMap in
+// value order. The iterator's keys are the values of the BiMap.
+func (b *BiMap[K, V]) InverseIterator() MapIterator[V, K] {
+	return b.rev.Iterator()
+}
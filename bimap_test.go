@@ -0,0 +1,92 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package btree
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"testing"
+)
+
+func TestBiMap(t *testing.T) {
+	rng := newRand(t)
+	b := MakeBiMap[int, string](cmp.Compare[int], cmp.Compare[string])
+	fwd, rev := map[int]string{}, map[string]int{}
+	check := func(b *BiMap[int, string], fwd map[int]string, rev map[string]int) {
+		t.Helper()
+		if b.Len() != len(fwd) || len(fwd) != len(rev) {
+			t.Fatalf("expected %d pairs, got %d", len(fwd), b.Len())
+		}
+		it := b.Iterator()
+		for it.First(); it.Valid(); it.Next() {
+			if v, ok := fwd[it.Cur()]; !ok || v != it.Value() {
+				t.Fatalf("unexpected pair %d:%s", it.Cur(), it.Value())
+			}
+		}
+		inv := b.InverseIterator()
+		for inv.First(); inv.Valid(); inv.Next() {
+			if k, ok := rev[inv.Cur()]; !ok || k != inv.Value() {
+				t.Fatalf("unexpected pair %s:%d", inv.Cur(), inv.Value())
+			}
+		}
+	}
+	var clone BiMap[int, string]
+	var cloneFwd map[int]string
+	var cloneRev map[string]int
+	for i := 0; i < 10000; i++ {
+		k, v := rng.Intn(500), fmt.Sprint(rng.Intn(500))
+		switch rng.Intn(4) {
+		case 0:
+			gotV, found := b.Delete(k)
+			if wantV, ok := fwd[k]; ok != found || gotV != wantV {
+				t.Fatalf("unexpected result deleting %d", k)
+			}
+			if v, ok := fwd[k]; ok {
+				delete(rev, v)
+				delete(fwd, k)
+			}
+		case 1:
+			gotK, found := b.DeleteValue(v)
+			if wantK, ok := rev[v]; ok != found || gotK != wantK {
+				t.Fatalf("unexpected result deleting %s", v)
+			}
+			if k, ok := rev[v]; ok {
+				delete(fwd, k)
+				delete(rev, v)
+			}
+		default:
+			prevV, hadK, prevK, hadV := b.Upsert(k, v)
+			if wantV, ok := fwd[k]; ok != hadK || prevV != wantV {
+				t.Fatalf("unexpected previous value for %d", k)
+			}
+			if wantK, ok := rev[v]; ok != hadV || prevK != wantK {
+				t.Fatalf("unexpected previous key for %s", v)
+			}
+			if hadK {
+				delete(rev, prevV)
+			}
+			if hadV {
+				delete(fwd, prevK)
+			}
+			fwd[k], rev[v] = v, k
+		}
+		if i == 5000 {
+			clone, cloneFwd, cloneRev = b.Clone(), maps.Clone(fwd), maps.Clone(rev)
+		}
+	}
+	check(&b, fwd, rev)
+	check(&clone, cloneFwd, cloneRev)
+}
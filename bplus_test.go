@@ -0,0 +1,122 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package btree
+
+import (
+	"cmp"
+	"math/rand"
+	"testing"
+)
+
+func TestBPlusMap(t *testing.T) {
+	rng := newRand(t)
+	const N = 20000
+	m := MakeBPlusMap[int, int](cmp.Compare[int])
+	ref := MakeMap[int, int](cmp.Compare[int])
+	var clones []Map[int, int]
+	var h UpsertHint[int, int]
+	check := func(m *Map[int, int]) {
+		t.Helper()
+		var seeks []int
+		for k := -1; k <= N; k += 1 + rng.Intn(50) {
+			seeks = append(seeks, k)
+		}
+		checkMatches(t, m, &ref, seeks)
+	}
+	for i := 0; i < 4*N; i++ {
+		k := rng.Intn(N)
+		switch r := rng.Intn(10); {
+		case r < 4:
+			m.Upsert(k, i)
+			ref.Upsert(k, i)
+		case r < 5:
+			m.UpsertWithHint(k, i, &h)
+			ref.Upsert(k, i)
+		default:
+			_, _, a := m.Delete(k)
+			_, _, b := ref.Delete(k)
+			if a != b {
+				t.Fatalf("expected delete of %d to return %v", k, b)
+			}
+		}
+		if i%(N/2) == 0 {
+			clones = append(clones, m.Clone())
+			check(&m)
+		}
+	}
+	check(&m)
+	m.Compact(1)
+	check(&m)
+	it := m.MutatingIterator()
+	for it.First(); it.Valid(); {
+		if it.Cur()%3 == 0 {
+			ref.Delete(it.Cur())
+			it.DeleteCurrent()
+			continue
+		}
+		ref.Upsert(it.Cur(), -it.Value())
+		it.SetValue(-it.Value())
+		it.Next()
+	}
+	check(&m)
+	for _, c := range clones {
+		c.Reset()
+	}
+}
+
+func BenchmarkScan(b *testing.B) {
+	const count = 1 << 20
+	for _, tc := range []struct {
+		name string
+		m    Map[int, int]
+	}{
+		{"btree", MakeOrderedMap[int, int]()},
+		{"bplus", MakeOrderedBPlusMap[int, int]()},
+	} {
+		for _, k := range rand.Perm(count) {
+			tc.m.Upsert(k, k)
+		}
+		b.Run(tc.name, func(b *testing.B) {
+			it := tc.m.Iterator()
+			for i := 0; i < b.N; i += count {
+				for it.First(); it.Valid(); it.Next() {
+				}
+			}
+		})
+	}
+}
+
+// TestBPlusMapOutOfLineCompact checks that the separators written by Compact,
+// which have no values, survive further mutation with out-of-line values.
+func TestBPlusMapOutOfLineCompact(t *testing.T) {
+	rng := newRand(t)
+	const N = 100000
+	m := MakeBPlusMap[int, int](cmp.Compare[int], WithOutOfLineValues(true))
+	ref := map[int]int{}
+	for i := 0; i < N; i++ {
+		m.Upsert(i, i)
+		ref[i] = i
+	}
+	for _, k := range rng.Perm(N)[:N*9/10] {
+		m.Delete(k)
+		delete(ref, k)
+	}
+	m.Compact(1)
+	for i := 0; i < N; i++ {
+		m.Upsert(i, -i)
+		ref[i] = -i
+	}
+	checkMap(t, &m, ref)
+}
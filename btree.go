@@ -14,7 +14,11 @@
 
 package btree
 
-import "github.com/ajwerner/btree/internal/abstract"
+import (
+	"cmp"
+
+	"github.com/ajwerner/btree/internal/abstract"
+)
 
 // Map is a ordered map from K to V.
 type Map[K, V any] struct {
@@ -22,9 +26,34 @@ type Map[K, V any] struct {
 }
 
 // MakeMap constructs a new Map with the provided comparison function.
-func MakeMap[K, V any](cmp func(K, K) int) Map[K, V] {
+func MakeMap[K, V any](cmp func(K, K) int, opts ...Option) Map[K, V] {
+	return Map[K, V]{
+		Map: abstract.MakeMap[K, V, struct{}](cmp, nil, opts...),
+	}
+}
+
+// MakeOrderedMap constructs a new Map for keys with a natural order. It is
+// equivalent to MakeMap with cmp.Compare but searches faster.
+func MakeOrderedMap[K cmp.Ordered, V any](opts ...Option) Map[K, V] {
+	return Map[K, V]{
+		Map: abstract.MakeOrderedMap[K, V, struct{}](nil, opts...),
+	}
+}
+
+// MakeBPlusMap constructs a new Map with the provided comparison function
+// which stores all of its entries in leaf nodes. Compared to MakeMap, this
+// layout makes iteration faster at the cost of slightly more memory.
+func MakeBPlusMap[K, V any](cmp func(K, K) int, opts ...Option) Map[K, V] {
 	return Map[K, V]{
-		Map: abstract.MakeMap[K, V, struct{}](cmp, nil),
+		Map: abstract.MakeBPlusMap[K, V, struct{}](cmp, nil, opts...),
+	}
+}
+
+// MakeOrderedBPlusMap is like MakeBPlusMap but for keys with a natural order,
+// as with MakeOrderedMap.
+func MakeOrderedBPlusMap[K cmp.Ordered, V any](opts ...Option) Map[K, V] {
+	return Map[K, V]{
+		Map: abstract.MakeOrderedBPlusMap[K, V, struct{}](nil, opts...),
 	}
 }
 
@@ -37,8 +66,26 @@ func (m *Map[K, V]) Clone() Map[K, V] {
 type Set[T any] Map[T, struct{}]
 
 // MakeSet constructs a new Set with the provided comparison function.
-func MakeSet[T any](cmp func(T, T) int) Set[T] {
-	return (Set[T])(MakeMap[T, struct{}](cmp))
+func MakeSet[T any](cmp func(T, T) int, opts ...Option) Set[T] {
+	return (Set[T])(MakeMap[T, struct{}](cmp, opts...))
+}
+
+// MakeOrderedSet constructs a new Set for items with a natural order. It is
+// equivalent to MakeSet with cmp.Compare but searches faster.
+func MakeOrderedSet[T cmp.Ordered](opts ...Option) Set[T] {
+	return (Set[T])(MakeOrderedMap[T, struct{}](opts...))
+}
+
+// MakeBPlusSet constructs a new Set with the provided comparison function
+// which stores all of its items in leaf nodes. See MakeBPlusMap.
+func MakeBPlusSet[T any](cmp func(T, T) int, opts ...Option) Set[T] {
+	return (Set[T])(MakeBPlusMap[T, struct{}](cmp, opts...))
+}
+
+// MakeOrderedBPlusSet is like MakeBPlusSet but for items with a natural
+// order, as with MakeOrderedSet.
+func MakeOrderedBPlusSet[T cmp.Ordered](opts ...Option) Set[T] {
+	return (Set[T])(MakeOrderedBPlusMap[T, struct{}](opts...))
 }
 
 // Clone clones the Set, lazily. It does so in constant time.
@@ -53,6 +100,22 @@ func (t *Set[T]) Upsert(item T) (replaced T, overwrote bool) {
 	return replaced, overwrote
 }
 
+// UpsertWithHint is like Upsert but uses and updates the provided hint to
+// accelerate insertions of items which are near each other.
+func (t *Set[T]) UpsertWithHint(item T, h *UpsertHint[T, struct{}]) (replaced T, overwrote bool) {
+	replaced, _, overwrote = t.Map.UpsertWithHint(item, struct{}{}, h)
+	return replaced, overwrote
+}
+
+// UpsertBatch upserts each of the provided items. It is most efficient when
+// the items are sorted.
+func (t *Set[T]) UpsertBatch(items []T) {
+	var h UpsertHint[T, struct{}]
+	for _, item := range items {
+		t.UpsertWithHint(item, &h)
+	}
+}
+
 // Delete removes the value with the provided key. It returns true if the
 // item existed in the set.
 func (t *Set[K]) Delete(item K) (removed bool) {
@@ -60,8 +123,44 @@ func (t *Set[K]) Delete(item K) (removed bool) {
 	return removed
 }
 
+// Entry is a key-value pair in a Map.
+type Entry[K, V any] = abstract.Entry[K, V]
+
+// UpsertHint accelerates repeated insertions of nearby keys into a Map or
+// Set. The zero value is ready to use.
+type UpsertHint[K, V any] = abstract.UpsertHint[K, V, struct{}]
+
+// CompactionStats describes the effect of a call to Compact.
+type CompactionStats = abstract.CompactionStats
+
 // MapIterator is an iterator for a Map.
 type MapIterator[K, V any] = abstract.Iterator[K, V, struct{}]
 
 // SetIterator is an iterator for a Set.
 type SetIterator[T any] = MapIterator[T, struct{}]
+
+// MapMutatingIterator is an iterator for a Map which can update or delete
+// the entry at its current position.
+type MapMutatingIterator[K, V any] = abstract.MutatingIterator[K, V, struct{}]
+
+// Position records the position of a MapIterator or SetIterator so that it
+// can later be restored into an iterator over the same version of the tree.
+type Position[K, V any] = abstract.Position[K, V, struct{}]
+
+// MapSnapshotIterator is an iterator over a pinned version of a Map. It must
+// be closed when no longer in use.
+type MapSnapshotIterator[K, V any] = abstract.SnapshotIterator[K, V, struct{}]
+
+// SetSnapshotIterator is an iterator over a pinned version of a Set. It must
+// be closed when no longer in use.
+type SetSnapshotIterator[T any] = MapSnapshotIterator[T, struct{}]
+
+// SetSnapshotLeakHandler sets the function invoked when a snapshot iterator
+// of any map is garbage collected without having been closed. The handler
+// exists to surface the missing call to Close, for example in tests. By
+// default, and when f is nil, leaks are not reported. The nodes pinned by a
+// leaked iterator are not returned to the map's allocator; they are left to
+// the garbage collector.
+func SetSnapshotLeakHandler(f func()) {
+	abstract.SetSnapshotLeakHandler(f)
+}
@@ -16,7 +16,13 @@ package btree
 
 import (
 	"cmp"
+	"fmt"
+	"maps"
+	"math/rand"
+	"runtime"
+	"slices"
 	"testing"
+	"time"
 )
 
 func TestBTree(t *testing.T) {
@@ -40,3 +46,371 @@ func TestBTree(t *testing.T) {
 		it.Next()
 	}
 }
+
+// TestCloneValues checks that mutating a clone, which copies the nodes on
+// the path to the mutation, preserves the values of both trees.
+func TestCloneValues(t *testing.T) {
+	tree := MakeMap[int, int](cmp.Compare[int])
+	const n = 1000
+	for i := 0; i < n; i++ {
+		tree.Upsert(i, i)
+	}
+	clone := tree.Clone()
+	clone.Upsert(n/2, -1)
+	clone.Upsert(n, n)
+	for i := 0; i < n; i++ {
+		if v, ok := tree.Get(i); !ok || v != i {
+			t.Fatalf("original: expected %d:%d, got %d:%d", i, i, i, v)
+		}
+		exp := i
+		if i == n/2 {
+			exp = -1
+		}
+		if v, ok := clone.Get(i); !ok || v != exp {
+			t.Fatalf("clone: expected %d:%d, got %d:%d", i, exp, i, v)
+		}
+	}
+}
+
+func TestSnapshotIterator(t *testing.T) {
+	m := MakeMap[int, int](cmp.Compare[int])
+	for i := 0; i < 1000; i++ {
+		m.Upsert(i, i)
+	}
+	it := m.SnapshotIterator()
+	defer it.Close()
+	for i := 0; i < 1000; i += 2 {
+		m.Delete(i)
+		m.Upsert(i+1, -i)
+	}
+	var n int
+	for it.First(); it.Valid(); it.Next() {
+		if it.Cur() != n || it.Value() != n {
+			t.Fatalf("expected %d:%d, got %d:%d", n, n, it.Cur(), it.Value())
+		}
+		n++
+	}
+	if n != 1000 {
+		t.Fatalf("expected 1000 entries, got %d", n)
+	}
+	if m.Len() != 500 {
+		t.Fatalf("expected 500 entries in map, got %d", m.Len())
+	}
+	it.Close()
+	if it.First(); it.Valid() {
+		t.Fatalf("expected closed iterator to be invalid")
+	}
+}
+
+func TestSnapshotIteratorLeak(t *testing.T) {
+	leaked := make(chan struct{}, 1)
+	defer SetSnapshotLeakHandler(nil)
+	SetSnapshotLeakHandler(func() {
+		select {
+		case leaked <- struct{}{}:
+		default:
+		}
+	})
+	const N = 1000
+	m := MakeSet(cmp.Compare[int])
+	for i := 0; i < N; i++ {
+		m.Upsert(i)
+	}
+	func() {
+		it := m.SnapshotIterator()
+		it.First()
+	}()
+	// The Map is mutated while the finalizer may run, which must not release
+	// the nodes which the Map shares with the leaked snapshot.
+	deadline := time.After(10 * time.Second)
+	for i := 0; ; i++ {
+		m.Delete(i % N)
+		runtime.GC()
+		m.Upsert(i % N)
+		select {
+		case <-leaked:
+			if m.Len() != N {
+				t.Fatalf("expected %d entries, got %d", N, m.Len())
+			}
+			it := m.Iterator()
+			j := 0
+			for it.First(); it.Valid(); it.Next() {
+				if it.Cur() != j {
+					t.Fatalf("expected %d, got %d", j, it.Cur())
+				}
+				j++
+			}
+			return
+		case <-deadline:
+			t.Fatalf("expected leaked snapshot iterator to be detected")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestIteratorCloneAndPosition(t *testing.T) {
+	const N = 20000
+	m := MakeSet(cmp.Compare[int])
+	for i := 0; i < N; i++ {
+		m.Upsert(i)
+	}
+	it := m.Iterator()
+	for _, start := range []int{0, 1, 126, 127, 128, 5000, N - 1} {
+		it.SeekGE(start)
+		c := it.Clone()
+		pos := it.Position()
+		for i := 0; i < 300 && it.Valid(); i++ {
+			it.Next()
+		}
+		if !c.Valid() || c.Cur() != start {
+			t.Fatalf("expected clone at %d", start)
+		}
+		for i := start; i < start+300 && i < N; i++ {
+			if c.Cur() != i {
+				t.Fatalf("expected %d, got %d", i, c.Cur())
+			}
+			c.Next()
+		}
+		fresh := m.Iterator()
+		if !fresh.SetPosition(pos) {
+			t.Fatalf("failed to restore position")
+		}
+		for i := start; i >= 0 && i > start-300; i-- {
+			if !fresh.Valid() || fresh.Cur() != i {
+				t.Fatalf("expected %d after restore", i)
+			}
+			fresh.Prev()
+		}
+	}
+	other := m.Clone()
+	other.Upsert(N)
+	it.First()
+	fresh := other.Iterator()
+	if fresh.SetPosition(it.Position()) || fresh.Valid() {
+		t.Fatalf("expected position from another version to be rejected")
+	}
+	// Replacing an entry modifies the map in place without changing its root.
+	it.SeekGE(N / 2)
+	pos := it.Position()
+	m.Upsert(N / 2)
+	fresh = m.Iterator()
+	if fresh.SetPosition(pos) || fresh.Valid() {
+		t.Fatalf("expected position from before an in-place change to be rejected")
+	}
+	// Rebuilding the map may reuse the pooled root node.
+	it = m.Iterator()
+	it.SeekGE(N / 2)
+	pos = it.Position()
+	m.Reset()
+	for i := 0; i < N; i++ {
+		m.Upsert(i)
+	}
+	fresh = m.Iterator()
+	if fresh.SetPosition(pos) || fresh.Valid() {
+		t.Fatalf("expected position from before a rebuild to be rejected")
+	}
+	// Positions of invalid iterators do not describe an entry.
+	it = m.Iterator()
+	it.Last()
+	it.Next()
+	if fresh.SetPosition(it.Position()) || fresh.Valid() {
+		t.Fatalf("expected position of an exhausted iterator to be rejected")
+	}
+}
+
+func TestMutatingIterator(t *testing.T) {
+	const N = 5000
+	m := MakeMap[int, int](cmp.Compare[int])
+	for i := 0; i < N; i++ {
+		m.Upsert(i, i)
+	}
+	clone := m.Clone()
+	defer clone.Reset()
+	it := m.MutatingIterator()
+	for it.First(); it.Valid(); {
+		if it.Cur()%2 == 0 {
+			it.DeleteCurrent()
+			continue
+		}
+		it.SetValue(-it.Value())
+		it.Next()
+	}
+	if m.Len() != N/2 {
+		t.Fatalf("expected %d entries, got %d", N/2, m.Len())
+	}
+	exp := 1
+	iter := m.Iterator()
+	for iter.First(); iter.Valid(); iter.Next() {
+		if iter.Cur() != exp || iter.Value() != -exp {
+			t.Fatalf("expected %d:%d, got %d:%d", exp, -exp, iter.Cur(), iter.Value())
+		}
+		exp += 2
+	}
+	exp = 0
+	iter = clone.Iterator()
+	for iter.First(); iter.Valid(); iter.Next() {
+		if iter.Cur() != exp || iter.Value() != exp {
+			t.Fatalf("clone modified: expected %d:%d, got %d:%d", exp, exp, iter.Cur(), iter.Value())
+		}
+		exp++
+	}
+	if exp != N {
+		t.Fatalf("expected %d entries in clone, got %d", N, exp)
+	}
+}
+
+func TestSmallMap(t *testing.T) {
+	type tiny int32
+	const maps = 1000
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	ms := make([]Map[tiny, tiny], maps)
+	for i := range ms {
+		ms[i] = MakeMap[tiny, tiny](cmp.Compare[tiny])
+		for j := tiny(0); j < 3; j++ {
+			ms[i].Upsert(j, j)
+		}
+	}
+	runtime.ReadMemStats(&after)
+	if perMap := (after.TotalAlloc - before.TotalAlloc) / maps; perMap > 512 {
+		t.Fatalf("expected small maps to use at most 512 bytes, used %d", perMap)
+	}
+	// Small maps must grow transparently and survive clones.
+	for i, m := range ms[:10] {
+		c := m.Clone()
+		for j := tiny(3); j < 300; j++ {
+			m.Upsert(j, j)
+		}
+		if c.Len() != 3 || m.Len() != 300 {
+			t.Fatalf("%d: expected 3 and 300 entries, got %d and %d", i, c.Len(), m.Len())
+		}
+		for j := tiny(0); j < 300; j++ {
+			if v, ok := m.Get(j); !ok || v != j {
+				t.Fatalf("%d: expected %d", i, j)
+			}
+		}
+	}
+}
+
+func BenchmarkSmallMap(b *testing.B) {
+	for _, size := range []int{1, 4, 8, 16} {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				m := MakeMap[int, int](cmp.Compare[int])
+				for j := 0; j < size; j++ {
+					m.Upsert(j, j)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkLargeMap measures operations on a map made of full-sized nodes,
+// whose performance must not suffer from the variable capacity of leaves.
+func BenchmarkLargeMap(b *testing.B) {
+	const count = 1 << 16
+	keys := rand.Perm(count)
+	m := MakeMap[int, int](cmp.Compare[int])
+	for _, k := range keys {
+		m.Upsert(k, k)
+	}
+	b.Run("get", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			m.Get(keys[i%count])
+		}
+	})
+	b.Run("upsert", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			m.Upsert(keys[i%count], i)
+		}
+	})
+	b.Run("delete-insert", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			k := keys[i%count]
+			m.Delete(k)
+			m.Upsert(k, k)
+		}
+	})
+	b.Run("scan", func(b *testing.B) {
+		it := m.Iterator()
+		it.First()
+		for i := 0; i < b.N; i++ {
+			if !it.Valid() {
+				it.First()
+			}
+			it.Next()
+		}
+	})
+}
+
+// newRand returns a source of randomness for a test, logging its seed so
+// that a failure can be reproduced.
+func newRand(t testing.TB) *rand.Rand {
+	t.Helper()
+	seed := time.Now().UnixNano()
+	t.Logf("seed: %d", seed)
+	return rand.New(rand.NewSource(seed))
+}
+
+// checkMap checks that m holds exactly the entries of ref.
+func checkMap[V comparable](t *testing.T, m *Map[int, V], ref map[int]V) {
+	t.Helper()
+	if m.Len() != len(ref) {
+		t.Fatalf("expected %d entries, got %d", len(ref), m.Len())
+	}
+	it := m.Iterator()
+	it.First()
+	for _, k := range slices.Sorted(maps.Keys(ref)) {
+		if !it.Valid() || it.Cur() != k || it.Value() != ref[k] {
+			t.Fatalf("expected entry for %d", k)
+		}
+		it.Next()
+	}
+	if it.Valid() {
+		t.Fatalf("unexpected entry for %d", it.Cur())
+	}
+}
+
+// checkMatches checks that iterating over m, in both directions, and seeking
+// to each of the keys in seeks behave as they do over ref.
+func checkMatches(t *testing.T, m, ref *Map[int, int], seeks []int) {
+	t.Helper()
+	a, b := m.Iterator(), ref.Iterator()
+	a.First()
+	for b.First(); b.Valid(); b.Next() {
+		if !a.Valid() || a.Cur() != b.Cur() || a.Value() != b.Value() {
+			t.Fatalf("forward iteration diverged from reference at %d", b.Cur())
+		}
+		a.Next()
+	}
+	a.Last()
+	for b.Last(); b.Valid(); b.Prev() {
+		if !a.Valid() || a.Cur() != b.Cur() {
+			t.Fatalf("reverse iteration diverged from reference at %d", b.Cur())
+		}
+		a.Prev()
+	}
+	if a.Valid() {
+		t.Fatalf("expected iterator to be exhausted")
+	}
+	for _, k := range seeks {
+		a.SeekGE(k)
+		b.SeekGE(k)
+		if a.Valid() != b.Valid() || (a.Valid() && (a.Cur() != b.Cur() || a.Value() != b.Value())) {
+			t.Fatalf("SeekGE(%d) diverged from reference", k)
+		}
+		a.SeekLT(k)
+		b.SeekLT(k)
+		if a.Valid() != b.Valid() || (a.Valid() && a.Cur() != b.Cur()) {
+			t.Fatalf("SeekLT(%d) diverged from reference", k)
+		}
+	}
+}
+
+func seq(lo, hi int) (s []int) {
+	for i := lo; i < hi; i++ {
+		s = append(s, i)
+	}
+	return s
+}
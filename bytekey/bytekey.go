@@ -0,0 +1,248 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package bytekey provides an ordered map for string and []byte keys which
+// stores keys sharing long prefixes compactly.
+package bytekey
+
+import "github.com/ajwerner/btree/internal/abstract"
+
+// Bytes is the set of types which may be used as keys.
+type Bytes interface {
+	~string | ~[]byte
+}
+
+// Map is an ordered map from K to V, ordered bytewise. The keys in each node
+// of the tree are stored as a single copy of their common prefix along with
+// their individual suffixes, and searches within a node compare only the
+// suffixes.
+//
+// Keys are copied into the map, so []byte keys may be reused by the caller.
+// Retrieving a key from the map requires reassembling it.
+type Map[K Bytes, V any] = AugmentedMap[K, V, struct{}]
+
+// AugmentedMap is a Map whose nodes additionally carry an augmentation of
+// type A which is maintained by an Updater.
+type AugmentedMap[K Bytes, V, A any] struct {
+	m abstract.Map[Key, V, A]
+}
+
+// MakeMap constructs a new Map.
+func MakeMap[K Bytes, V any](opts ...Option) Map[K, V] {
+	return MakeAugmentedMap[K, V, struct{}](nil, opts...)
+}
+
+// MakeAugmentedMap constructs a new AugmentedMap whose augmentations are
+// maintained by the provided Updater.
+func MakeAugmentedMap[K Bytes, V, A any](
+	up Updater[V, A], opts ...Option,
+) AugmentedMap[K, V, A] {
+	return AugmentedMap[K, V, A]{
+		m: abstract.MakeCompressedMap[Key, V, A](compareKeys, compressor{}, up, opts...),
+	}
+}
+
+// Clone clones the Map, lazily. It does so in constant time.
+func (t *AugmentedMap[K, V, A]) Clone() AugmentedMap[K, V, A] {
+	return AugmentedMap[K, V, A]{m: t.m.Clone()}
+}
+
+// Reset removes all items from the Map. In doing so, it allows memory held
+// by the Map to be recycled.
+func (t *AugmentedMap[K, V, A]) Reset() {
+	t.m.Reset()
+}
+
+// Len returns the number of items currently in the Map.
+func (t *AugmentedMap[K, V, A]) Len() int {
+	return t.m.Len()
+}
+
+// Height returns the height of the tree.
+func (t *AugmentedMap[K, V, A]) Height() int {
+	return t.m.Height()
+}
+
+// Upsert inserts or updates the value for the provided key. It returns the
+// overwritten key and value if a previous value existed for the key.
+func (t *AugmentedMap[K, V, A]) Upsert(k K, v V) (replacedK K, replacedV V, replaced bool) {
+	rk, replacedV, replaced := t.m.Upsert(makeKey(k), v)
+	if replaced {
+		replacedK = K(rk.String())
+	}
+	return replacedK, replacedV, replaced
+}
+
+// Get returns the value associated with the requested key, if it exists.
+func (t *AugmentedMap[K, V, A]) Get(k K) (v V, ok bool) {
+	return t.m.Get(makeKey(k))
+}
+
+// Delete removes the value with the provided key. It returns the removed
+// key and value if the key existed in the Map.
+func (t *AugmentedMap[K, V, A]) Delete(k K) (removedK K, v V, found bool) {
+	rk, v, found := t.m.Delete(makeKey(k))
+	if found {
+		removedK = K(rk.String())
+	}
+	return removedK, v, found
+}
+
+// Compact rewrites the tree into densely packed nodes. See btree.Map.Compact.
+func (t *AugmentedMap[K, V, A]) Compact(targetFill float64) CompactionStats {
+	return t.m.Compact(targetFill)
+}
+
+// String returns a string description of the tree.
+func (t *AugmentedMap[K, V, A]) String() string {
+	return t.m.String()
+}
+
+// Iterator constructs a new Iterator for this Map.
+func (t *AugmentedMap[K, V, A]) Iterator() AugmentedIterator[K, V, A] {
+	return AugmentedIterator[K, V, A]{Iterator: t.m.Iterator()}
+}
+
+// SnapshotIterator constructs a new SnapshotIterator over the current
+// version of this Map. The iterator is unaffected by subsequent mutations
+// to the Map and must be closed when no longer in use.
+func (t *AugmentedMap[K, V, A]) SnapshotIterator() AugmentedSnapshotIterator[K, V, A] {
+	s := t.m.Snapshot()
+	return AugmentedSnapshotIterator[K, V, A]{
+		AugmentedIterator: AugmentedIterator[K, V, A]{Iterator: s.Iterator()},
+		s:                 s,
+	}
+}
+
+// MutatingIterator constructs a new MutatingIterator for this Map.
+func (t *AugmentedMap[K, V, A]) MutatingIterator() AugmentedMutatingIterator[K, V, A] {
+	return AugmentedMutatingIterator[K, V, A]{AugmentedIterator: t.Iterator()}
+}
+
+// CompactionStats describes the effect of a call to Compact.
+type CompactionStats = abstract.CompactionStats
+
+// Option configures a Map. See btree.WithAllocator.
+type Option = abstract.Option
+
+// Position records the position of an Iterator so that it can later be
+// restored into an Iterator over the same version of the Map.
+type Position[V any] = AugmentedPosition[V, struct{}]
+
+// AugmentedPosition is a Position for an AugmentedMap.
+type AugmentedPosition[V, A any] = abstract.Position[Key, V, A]
+
+// Updater maintains the augmentations of the nodes of an AugmentedMap. It is
+// called with a node whenever its contents change.
+type Updater[V, A any] = abstract.Updater[Key, V, A]
+
+// Node is a node of an AugmentedMap as passed to an Updater.
+type Node[V, A any] = abstract.Node[Key, V, A]
+
+// UpdateInfo describes the change to a node passed to an Updater.
+type UpdateInfo[A any] = abstract.UpdateInfo[Key, A]
+
+// Action classifies the change described by an UpdateInfo.
+type Action = abstract.Action
+
+// The Actions which may be described by an UpdateInfo. An Updater which is
+// passed Default must recompute the augmentation from the node's keys and
+// the augmentations of its children.
+const (
+	Default   = abstract.Default
+	Split     = abstract.Split
+	Removal   = abstract.Removal
+	Insertion = abstract.Insertion
+)
+
+// Iterator is an iterator for a Map.
+type Iterator[K Bytes, V any] = AugmentedIterator[K, V, struct{}]
+
+// AugmentedIterator is an iterator for an AugmentedMap.
+type AugmentedIterator[K Bytes, V, A any] struct {
+	abstract.Iterator[Key, V, A]
+}
+
+// Clone returns an independent Iterator at the same position.
+func (i *AugmentedIterator[K, V, A]) Clone() AugmentedIterator[K, V, A] {
+	return AugmentedIterator[K, V, A]{Iterator: i.Iterator.Clone()}
+}
+
+// Compare compares two keys using the same ordering as the Map.
+func (i *AugmentedIterator[K, V, A]) Compare(a, b K) int {
+	return compareKeys(makeKey(a), makeKey(b))
+}
+
+// SeekGE seeks to the first key greater-than or equal to the provided key.
+func (i *AugmentedIterator[K, V, A]) SeekGE(k K) {
+	i.Iterator.SeekGE(makeKey(k))
+}
+
+// SeekLT seeks to the last key less-than the provided key.
+func (i *AugmentedIterator[K, V, A]) SeekLT(k K) {
+	i.Iterator.SeekLT(makeKey(k))
+}
+
+// Cur returns the key at the Iterator's current position. It is illegal
+// to call Cur if the Iterator is not valid.
+func (i *AugmentedIterator[K, V, A]) Cur() K {
+	return K(i.Iterator.Cur().String())
+}
+
+// AppendCur appends the key at the Iterator's current position to buf.
+// Unlike Cur, it does not allocate if buf has sufficient capacity. It is
+// illegal to call AppendCur if the Iterator is not valid.
+func (i *AugmentedIterator[K, V, A]) AppendCur(buf []byte) []byte {
+	k := i.Iterator.Cur()
+	return append(append(buf, k.prefix...), k.suffix...)
+}
+
+// SnapshotIterator is an Iterator over a pinned version of a Map. It must be
+// closed when no longer in use.
+type SnapshotIterator[K Bytes, V any] = AugmentedSnapshotIterator[K, V, struct{}]
+
+// AugmentedSnapshotIterator is a SnapshotIterator for an AugmentedMap.
+type AugmentedSnapshotIterator[K Bytes, V, A any] struct {
+	AugmentedIterator[K, V, A]
+	s *abstract.Snapshot[Key, V, A]
+}
+
+// Close releases the version of the Map pinned by the iterator. The
+// iterator is invalid after Close returns.
+func (i *AugmentedSnapshotIterator[K, V, A]) Close() {
+	i.s.Close()
+	i.Reset()
+}
+
+// MutatingIterator is an Iterator which can additionally update or delete
+// the entry at its current position.
+type MutatingIterator[K Bytes, V any] = AugmentedMutatingIterator[K, V, struct{}]
+
+// AugmentedMutatingIterator is a MutatingIterator for an AugmentedMap.
+type AugmentedMutatingIterator[K Bytes, V, A any] struct {
+	AugmentedIterator[K, V, A]
+}
+
+// SetValue replaces the value at the iterator's current position. It is
+// illegal to call SetValue if the iterator is not valid.
+func (i *AugmentedMutatingIterator[K, V, A]) SetValue(v V) {
+	abstract.LowLevel(&i.AugmentedIterator.Iterator).SetValue(v)
+}
+
+// DeleteCurrent removes the entry at the iterator's current position and
+// positions the iterator at the entry which followed it. It is illegal to
+// call DeleteCurrent if the iterator is not valid.
+func (i *AugmentedMutatingIterator[K, V, A]) DeleteCurrent() {
+	abstract.LowLevel(&i.AugmentedIterator.Iterator).DeleteCurrent()
+}
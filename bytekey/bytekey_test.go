@@ -0,0 +1,211 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package bytekey
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ajwerner/btree"
+	"github.com/ajwerner/btree/internal/abstract"
+	"github.com/stretchr/testify/require"
+)
+
+func randomKey(rng *rand.Rand) string {
+	tables := []string{"/table/users/", "/table/users/index/email/", "/table/orders/", "/tenant/"}
+	return fmt.Sprintf("%s%d", tables[rng.Intn(len(tables))], rng.Intn(5000))
+}
+
+func TestMap(t *testing.T) {
+	rng := newRand(t)
+	m := MakeMap[string, int]()
+	ref := btree.MakeOrderedMap[string, int]()
+	var clones []Map[string, int]
+	for i := 0; i < 50000; i++ {
+		k := randomKey(rng)
+		if rng.Intn(3) == 0 {
+			rk, _, found := m.Delete(k)
+			_, _, refFound := ref.Delete(k)
+			require.Equal(t, refFound, found)
+			if found {
+				require.Equal(t, k, rk)
+			}
+			continue
+		}
+		m.Upsert(k, i)
+		ref.Upsert(k, i)
+		if i%10000 == 0 {
+			clones = append(clones, m.Clone())
+		}
+	}
+	require.Equal(t, ref.Len(), m.Len())
+	a, b := m.Iterator(), ref.Iterator()
+	a.First()
+	for b.First(); b.Valid(); b.Next() {
+		require.True(t, a.Valid())
+		require.Equal(t, b.Cur(), a.Cur())
+		require.Equal(t, b.Value(), a.Value())
+		require.Equal(t, b.Cur(), string(a.AppendCur(nil)))
+		a.Next()
+	}
+	require.False(t, a.Valid())
+	for i := 0; i < 1000; i++ {
+		k := randomKey(rng)
+		k = k[:rng.Intn(len(k)+1)]
+		a.SeekGE(k)
+		b.SeekGE(k)
+		require.Equal(t, b.Valid(), a.Valid())
+		if b.Valid() {
+			require.Equal(t, b.Cur(), a.Cur())
+		}
+		a.SeekLT(k)
+		b.SeekLT(k)
+		require.Equal(t, b.Valid(), a.Valid())
+		if b.Valid() {
+			require.Equal(t, b.Cur(), a.Cur())
+		}
+	}
+	for _, c := range clones {
+		it := c.Iterator()
+		var prev string
+		for it.First(); it.Valid(); it.Next() {
+			require.Less(t, prev, it.Cur())
+			prev = it.Cur()
+		}
+		c.Reset()
+	}
+}
+
+func TestBytesKeys(t *testing.T) {
+	m := MakeMap[[]byte, int]()
+	buf := []byte("/prefix/")
+	for i := 0; i < 1000; i++ {
+		k := fmt.Appendf(buf[:8], "%04d", i)
+		m.Upsert(k, i)
+	}
+	// Mutating the caller's buffer must not affect the map.
+	copy(buf, "xxxxxxxx")
+	it := m.Iterator()
+	it.SeekGE([]byte("/prefix/0500"))
+	require.Equal(t, "/prefix/0500", string(it.Cur()))
+	v, ok := m.Get([]byte("/prefix/0999"))
+	require.True(t, ok)
+	require.Equal(t, 999, v)
+}
+
+func TestCompression(t *testing.T) {
+	const N = 20000
+	prefix := strings.Repeat("/a/long/shared/prefix", 10)
+	// Without compression, the prefixes alone would occupy N*len(prefix)
+	// bytes.
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	m := MakeMap[string, struct{}]()
+	for i := 0; i < N; i++ {
+		m.Upsert(fmt.Sprintf("%s/%08d", prefix, i), struct{}{})
+	}
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	if used := int(after.HeapAlloc) - int(before.HeapAlloc); used > N*len(prefix) {
+		t.Fatalf("expected compressed keys to use less than %d bytes, used %d", N*len(prefix), used)
+	}
+	it := m.Iterator()
+	it.SeekGE(prefix + "/00012345")
+	require.Equal(t, prefix+"/00012345", it.Cur())
+}
+
+func TestMutatingIterator(t *testing.T) {
+	m := MakeMap[string, int]()
+	for i := 0; i < 1000; i++ {
+		m.Upsert(fmt.Sprintf("key/%04d", i), i)
+	}
+	it := m.MutatingIterator()
+	for it.First(); it.Valid(); {
+		if it.Value()%2 == 0 {
+			it.DeleteCurrent()
+			continue
+		}
+		it.SetValue(-it.Value())
+		it.Next()
+	}
+	require.Equal(t, 500, m.Len())
+	v, ok := m.Get("key/0001")
+	require.True(t, ok)
+	require.Equal(t, -1, v)
+}
+
+// lengthAug records the number of keys in a subtree and the length of the
+// longest of them.
+type lengthAug struct {
+	count, maxLen int
+}
+
+type lengthUpdater struct{}
+
+func (lengthUpdater) Update(n *Node[int, lengthAug], _ UpdateInfo[lengthAug]) bool {
+	var a lengthAug
+	for i := int16(0); i < n.Count(); i++ {
+		a.count++
+		a.maxLen = max(a.maxLen, len(n.GetKey(i).String()))
+	}
+	for i := int16(0); !n.IsLeaf() && i <= n.Count(); i++ {
+		if c := n.GetChild(i); c != nil {
+			a.count += c.count
+			a.maxLen = max(a.maxLen, c.maxLen)
+		}
+	}
+	changed := a != *n.GetA()
+	*n.GetA() = a
+	return changed
+}
+
+func TestAugmentedMap(t *testing.T) {
+	rng := newRand(t)
+	m := MakeAugmentedMap[string, int, lengthAug](lengthUpdater{})
+	ref := map[string]int{}
+	for i := 0; i < 20000; i++ {
+		k := randomKey(rng)
+		if rng.Intn(3) == 0 {
+			m.Delete(k)
+			delete(ref, k)
+		} else {
+			m.Upsert(k, i)
+			ref[k] = i
+		}
+	}
+	var exp lengthAug
+	for k := range ref {
+		exp.count++
+		exp.maxLen = max(exp.maxLen, len(k))
+	}
+	it := m.Iterator()
+	root := abstract.LowLevel(&it.Iterator).Node()
+	require.Equal(t, exp, *root.GetA())
+	require.Equal(t, len(ref), m.Len())
+}
+
+// newRand returns a source of randomness for a test, logging its seed so
+// that a failure can be reproduced.
+func newRand(t testing.TB) *rand.Rand {
+	t.Helper()
+	seed := time.Now().UnixNano()
+	t.Logf("seed: %d", seed)
+	return rand.New(rand.NewSource(seed))
+}
@@ -0,0 +1,180 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package bytekey
+
+import (
+	"strings"
+	"unsafe"
+)
+
+// Key is a key as stored in a Map and passed to an Updater. It is split into
+// a prefix and a suffix; the keys in a node share the storage of their common
+// prefix, see compressor.
+type Key struct {
+	prefix, suffix string
+}
+
+func makeKey[K ~string | ~[]byte](k K) Key {
+	return Key{suffix: string(k)}
+}
+
+// String returns the bytes of the key as a string.
+func (k Key) String() string {
+	if k.prefix == "" {
+		return k.suffix
+	}
+	return k.prefix + k.suffix
+}
+
+// same returns true if a and b refer to the same storage.
+func same(a, b string) bool {
+	return len(a) == len(b) &&
+		(len(a) == 0 || unsafe.StringData(a) == unsafe.StringData(b))
+}
+
+// compareKeys compares the keys represented by a and b.
+func compareKeys(a, b Key) int {
+	if same(a.prefix, b.prefix) {
+		return strings.Compare(a.suffix, b.suffix)
+	}
+	a0, a1, b0, b1 := a.prefix, a.suffix, b.prefix, b.suffix
+	for {
+		if len(a0) == 0 {
+			a0, a1 = a1, ""
+		}
+		if len(b0) == 0 {
+			b0, b1 = b1, ""
+		}
+		if len(a0) == 0 || len(b0) == 0 {
+			return len(a0) - len(b0)
+		}
+		n := min(len(a0), len(b0))
+		if c := strings.Compare(a0[:n], b0[:n]); c != 0 {
+			return c
+		}
+		a0, b0 = a0[n:], b0[n:]
+	}
+}
+
+// commonPrefixLen returns the length of the common prefix of the keys
+// represented by a and b.
+func commonPrefixLen(a, b Key) (n int) {
+	if same(a.prefix, b.prefix) {
+		return len(a.prefix) + lcp(a.suffix, b.suffix)
+	}
+	a0, a1, b0, b1 := a.prefix, a.suffix, b.prefix, b.suffix
+	for {
+		if len(a0) == 0 {
+			a0, a1 = a1, ""
+		}
+		if len(b0) == 0 {
+			b0, b1 = b1, ""
+		}
+		m := min(len(a0), len(b0))
+		l := lcp(a0[:m], b0[:m])
+		n += l
+		if m == 0 || l < m {
+			return n
+		}
+		a0, b0 = a0[m:], b0[m:]
+	}
+}
+
+func lcp(a, b string) int {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
+
+// compressor implements abstract.Compressor for keys. It stores the common
+// prefix of the keys in each node once and compares only the suffixes of
+// keys which share it.
+type compressor struct{}
+
+func (compressor) Search(keys []Key, item Key) (index int, found bool) {
+	if len(keys) == 0 {
+		return 0, false
+	}
+	p := keys[0].prefix
+	if p == "" || item.prefix != "" || !same(p, keys[len(keys)-1].prefix) {
+		return search(keys, item, compareKeys)
+	}
+	// The first and last keys share the prefix, so, being sorted, all of the
+	// keys start with it.
+	if !strings.HasPrefix(item.suffix, p) {
+		if item.suffix < p {
+			return 0, false
+		}
+		return len(keys), false
+	}
+	suffix := Key{prefix: p, suffix: item.suffix[len(p):]}
+	return search(keys, suffix, compareKeys)
+}
+
+func search(keys []Key, item Key, cmp func(a, b Key) int) (index int, found bool) {
+	i, j := 0, len(keys)
+	for i < j {
+		h := int(uint(i+j) >> 1) // avoid overflow when computing h
+		// i ≤ h < j
+		c := cmp(item, keys[h])
+		if c < 0 {
+			j = h
+		} else if c > 0 {
+			i = h + 1
+		} else {
+			return h, true
+		}
+	}
+	return i, false
+}
+
+// Compress rewrites the keys to share a single copy of their common prefix.
+// Suffixes which may refer to the storage of a larger string provided by
+// the caller are copied so that the larger string can be collected.
+func (compressor) Compress(keys []Key) {
+	if len(keys) == 0 {
+		return
+	}
+	first, last := keys[0], keys[len(keys)-1]
+	n := commonPrefixLen(first, last)
+	p := first.prefix
+	switch {
+	case len(p) == n:
+	case len(p) > n:
+		p = p[:n]
+	case p == "":
+		p = strings.Clone(first.suffix[:n])
+	default:
+		p += first.suffix[:n-len(p)]
+	}
+	for i := range keys {
+		k := &keys[i]
+		switch {
+		case same(k.prefix, p):
+			continue
+		case len(k.prefix) > len(p):
+			k.suffix = k.prefix[len(p):] + k.suffix
+		case k.prefix == "":
+			k.suffix = strings.Clone(k.suffix[len(p):])
+		default:
+			k.suffix = k.suffix[len(p)-len(k.prefix):]
+		}
+		k.prefix = p
+	}
+}
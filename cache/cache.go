@@ -0,0 +1,325 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package cache provides an ordered cache with expiration and eviction of
+// the least recently used entries.
+package cache
+
+import (
+	"time"
+
+	"github.com/ajwerner/btree"
+)
+
+// Config configures a Cache.
+type Config[K, V any] struct {
+
+	// TTL, if positive, is the duration after which an entry expires. It is
+	// measured from the entry's last use: its last write if
+	// ExpireAfterWrite is set and otherwise its last read or write.
+	TTL time.Duration
+
+	// ExpireAfterWrite, if true, prevents reads from refreshing entries.
+	// Entries then expire TTL after they are written and are evicted in the
+	// order in which they were written rather than least recently used
+	// first.
+	ExpireAfterWrite bool
+
+	// MaxBytes, if positive, bounds the total size of the entries. Entries
+	// are evicted, least recently used first, to stay within the budget.
+	MaxBytes int64
+
+	// Size returns the size of an entry. If nil, every entry has size 1 so
+	// that MaxBytes bounds the number of entries.
+	Size func(K, V) int64
+
+	// Now returns the current time. If nil, time.Now is used.
+	Now func() time.Time
+}
+
+// Cache is a map from K to V ordered by K whose entries expire and are
+// evicted in the order of their last use. It consists of two ordered
+// indexes: one by key and one by last use. Both are copy-on-write, so
+// taking a Snapshot of the Cache is cheap.
+//
+// Write operations, which include Get, are not safe for concurrent use.
+type Cache[K, V any] struct {
+	cfg   Config[K, V]
+	byKey btree.Map[K, entry[V]]
+	byUse btree.Map[uint64, K]
+	bytes int64
+
+	// seq is the sequence number of the most recent use of an entry.
+	seq uint64
+}
+
+// entry is a value in a Cache along with the time and sequence number of
+// its last use.
+type entry[V any] struct {
+	v    V
+	seq  uint64
+	used time.Time
+	size int64
+}
+
+// MakeCache constructs a new Cache with the provided comparison function for
+// keys and configuration.
+func MakeCache[K, V any](cmp func(K, K) int, cfg Config[K, V]) Cache[K, V] {
+	if cfg.Size == nil {
+		cfg.Size = func(K, V) int64 { return 1 }
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return Cache[K, V]{
+		cfg:   cfg,
+		byKey: btree.MakeMap[K, entry[V]](cmp),
+		byUse: btree.MakeOrderedMap[uint64, K](),
+	}
+}
+
+// Len returns the number of entries in the Cache, including any which have
+// expired but not yet been removed.
+func (c *Cache[K, V]) Len() int {
+	return c.byKey.Len()
+}
+
+// Bytes returns the total size of the entries in the Cache.
+func (c *Cache[K, V]) Bytes() int64 {
+	return c.bytes
+}
+
+// Reset removes all entries from the Cache.
+func (c *Cache[K, V]) Reset() {
+	c.byKey.Reset()
+	c.byUse.Reset()
+	c.bytes = 0
+}
+
+// Put inserts or replaces the value for the key, evicting entries as
+// necessary to stay within the budget.
+func (c *Cache[K, V]) Put(k K, v V) {
+	now := c.cfg.Now()
+	c.expire(now)
+	if old, ok := c.byKey.Get(k); ok {
+		c.byUse.Delete(old.seq)
+		c.bytes -= old.size
+	}
+	e := entry[V]{v: v, size: c.cfg.Size(k, v)}
+	c.use(k, &e, now)
+	c.bytes += e.size
+	for c.cfg.MaxBytes > 0 && c.bytes > c.cfg.MaxBytes && c.byUse.Len() > 0 {
+		c.evictOldest()
+	}
+}
+
+// Get returns the value for the key if it exists and has not expired. Unless
+// ExpireAfterWrite is set, the entry becomes the most recently used.
+func (c *Cache[K, V]) Get(k K) (v V, ok bool) {
+	e, ok := c.byKey.Get(k)
+	if !ok {
+		return v, false
+	}
+	now := c.cfg.Now()
+	if c.expired(&e, now) {
+		c.Delete(k)
+		return v, false
+	}
+	if !c.cfg.ExpireAfterWrite {
+		c.byUse.Delete(e.seq)
+		c.use(k, &e, now)
+	}
+	return e.v, true
+}
+
+// Delete removes the entry for the key. It returns true if the entry
+// existed.
+func (c *Cache[K, V]) Delete(k K) (removed bool) {
+	_, e, removed := c.byKey.Delete(k)
+	if removed {
+		c.byUse.Delete(e.seq)
+		c.bytes -= e.size
+	}
+	return removed
+}
+
+// Expire removes all expired entries. It returns the number of entries
+// removed.
+func (c *Cache[K, V]) Expire() (removed int) {
+	return c.expire(c.cfg.Now())
+}
+
+// Snapshot returns a view of the current contents of the Cache which is
+// unaffected by subsequent operations on the Cache. It does so in constant
+// time. The Snapshot should be closed when no longer in use.
+func (c *Cache[K, V]) Snapshot() Snapshot[K, V] {
+	return Snapshot[K, V]{cfg: &c.cfg, byKey: c.byKey.Clone()}
+}
+
+// Iterator constructs an iterator over the live entries of the Cache in key
+// order. Iteration does not affect the order of eviction.
+func (c *Cache[K, V]) Iterator() Iterator[K, V] {
+	return Iterator[K, V]{it: c.byKey.Iterator(), cfg: &c.cfg}
+}
+
+// use records a use of the entry at the provided time.
+func (c *Cache[K, V]) use(k K, e *entry[V], now time.Time) {
+	c.seq++
+	e.seq, e.used = c.seq, now
+	c.byKey.Upsert(k, *e)
+	c.byUse.Upsert(e.seq, k)
+}
+
+func (c *Cache[K, V]) expired(e *entry[V], now time.Time) bool {
+	return isExpired(&c.cfg, e, now)
+}
+
+func isExpired[K, V any](cfg *Config[K, V], e *entry[V], now time.Time) bool {
+	return cfg.TTL > 0 && !now.Before(e.used.Add(cfg.TTL))
+}
+
+// expire removes the entries which have expired at the provided time. As
+// entries are indexed by their last use, the expired entries are the first
+// in that index.
+func (c *Cache[K, V]) expire(now time.Time) (removed int) {
+	if c.cfg.TTL <= 0 {
+		return 0
+	}
+	it := c.byUse.Iterator()
+	for it.First(); it.Valid(); it.First() {
+		e, _ := c.byKey.Get(it.Value())
+		if !c.expired(&e, now) {
+			break
+		}
+		c.Delete(it.Value())
+		removed++
+	}
+	return removed
+}
+
+// evictOldest removes the least recently used entry.
+func (c *Cache[K, V]) evictOldest() {
+	it := c.byUse.Iterator()
+	it.First()
+	c.Delete(it.Value())
+}
+
+// Snapshot is a read-only view of a Cache at a point in time.
+type Snapshot[K, V any] struct {
+	cfg   *Config[K, V]
+	byKey btree.Map[K, entry[V]]
+}
+
+// Len returns the number of entries in the Snapshot, including any which
+// have expired.
+func (s *Snapshot[K, V]) Len() int {
+	return s.byKey.Len()
+}
+
+// Get returns the value for the key if it exists and has not expired.
+func (s *Snapshot[K, V]) Get(k K) (v V, ok bool) {
+	e, ok := s.byKey.Get(k)
+	if !ok || isExpired(s.cfg, &e, s.cfg.Now()) {
+		return v, false
+	}
+	return e.v, true
+}
+
+// Iterator constructs an iterator over the live entries of the Snapshot in
+// key order.
+func (s *Snapshot[K, V]) Iterator() Iterator[K, V] {
+	return Iterator[K, V]{it: s.byKey.Iterator(), cfg: s.cfg}
+}
+
+// Close releases the memory held by the Snapshot.
+func (s *Snapshot[K, V]) Close() {
+	s.byKey.Reset()
+}
+
+// Iterator is an iterator over the live entries of a Cache or Snapshot in
+// key order. Expired entries are skipped.
+type Iterator[K, V any] struct {
+	it  btree.MapIterator[K, entry[V]]
+	cfg *Config[K, V]
+	now time.Time
+}
+
+// First seeks to the first live entry.
+func (i *Iterator[K, V]) First() {
+	i.now = i.cfg.Now()
+	i.it.First()
+	i.skipForward()
+}
+
+// Last seeks to the last live entry.
+func (i *Iterator[K, V]) Last() {
+	i.now = i.cfg.Now()
+	i.it.Last()
+	i.skipBackward()
+}
+
+// SeekGE seeks to the first live entry with a key greater-than or equal to
+// the provided key.
+func (i *Iterator[K, V]) SeekGE(k K) {
+	i.now = i.cfg.Now()
+	i.it.SeekGE(k)
+	i.skipForward()
+}
+
+// SeekLT seeks to the last live entry with a key less-than the provided key.
+func (i *Iterator[K, V]) SeekLT(k K) {
+	i.now = i.cfg.Now()
+	i.it.SeekLT(k)
+	i.skipBackward()
+}
+
+// Next positions the iterator to the following live entry.
+func (i *Iterator[K, V]) Next() {
+	i.it.Next()
+	i.skipForward()
+}
+
+// Prev positions the iterator to the previous live entry.
+func (i *Iterator[K, V]) Prev() {
+	i.it.Prev()
+	i.skipBackward()
+}
+
+// Valid returns whether the iterator is positioned at a valid position.
+func (i *Iterator[K, V]) Valid() bool { return i.it.Valid() }
+
+// Cur returns the key at the iterator's current position. It is illegal to
+// call Cur if the iterator is not valid.
+func (i *Iterator[K, V]) Cur() K { return i.it.Cur() }
+
+// Value returns the value at the iterator's current position. It is illegal
+// to call Value if the iterator is not valid.
+func (i *Iterator[K, V]) Value() V { return i.it.Value().v }
+
+func (i *Iterator[K, V]) skipForward() {
+	for i.it.Valid() && i.expired() {
+		i.it.Next()
+	}
+}
+
+func (i *Iterator[K, V]) skipBackward() {
+	for i.it.Valid() && i.expired() {
+		i.it.Prev()
+	}
+}
+
+func (i *Iterator[K, V]) expired() bool {
+	e := i.it.Value()
+	return isExpired(i.cfg, &e, i.now)
+}
@@ -0,0 +1,134 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cache
+
+import (
+	"cmp"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type clock struct{ now time.Time }
+
+func (c *clock) Now() time.Time { return c.now }
+
+func (c *clock) advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func keys[K, V any](it Iterator[K, V]) (ks []K) {
+	for it.First(); it.Valid(); it.Next() {
+		ks = append(ks, it.Cur())
+	}
+	return ks
+}
+
+func TestLRU(t *testing.T) {
+	c := MakeCache[string, string](cmp.Compare[string], Config[string, string]{
+		MaxBytes: 10,
+		Size:     func(k, v string) int64 { return int64(len(v)) },
+	})
+	c.Put("a", "aaa")
+	c.Put("b", "bbb")
+	c.Put("c", "ccc")
+	require.Equal(t, int64(9), c.Bytes())
+	// Reading a makes b the least recently used.
+	_, ok := c.Get("a")
+	require.True(t, ok)
+	c.Put("d", "dd")
+	require.Equal(t, []string{"a", "c", "d"}, keys(c.Iterator()))
+	require.Equal(t, int64(8), c.Bytes())
+	// Replacing an entry accounts for its new size and makes it the most
+	// recently used.
+	c.Put("c", "cccccc")
+	require.Equal(t, []string{"c", "d"}, keys(c.Iterator()))
+	require.Equal(t, int64(8), c.Bytes())
+	require.True(t, c.Delete("d"))
+	require.False(t, c.Delete("d"))
+	require.Equal(t, int64(6), c.Bytes())
+}
+
+func TestTTL(t *testing.T) {
+	for _, afterWrite := range []bool{false, true} {
+		t.Run(fmt.Sprint("afterWrite=", afterWrite), func(t *testing.T) {
+			clk := &clock{now: time.Unix(0, 0)}
+			c := MakeCache[int, int](cmp.Compare[int], Config[int, int]{
+				TTL:              time.Minute,
+				ExpireAfterWrite: afterWrite,
+				Now:              clk.Now,
+			})
+			for i := 0; i < 10; i++ {
+				c.Put(i, i)
+				clk.advance(time.Second)
+			}
+			// Entries 0 through 4 are refreshed unless expiry is measured from
+			// writes.
+			clk.advance(40 * time.Second)
+			for i := 0; i < 5; i++ {
+				_, ok := c.Get(i)
+				require.True(t, ok)
+			}
+			clk.advance(18 * time.Second)
+			// The scan skips expired entries before they are removed.
+			var want []int
+			if !afterWrite {
+				want = []int{0, 1, 2, 3, 4, 9}
+			} else {
+				want = []int{9}
+			}
+			require.Equal(t, want, keys(c.Iterator()))
+			require.Equal(t, 10-len(want), c.Expire())
+			require.Equal(t, len(want), c.Len())
+			_, ok := c.Get(want[0])
+			require.True(t, ok)
+		})
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	clk := &clock{now: time.Unix(0, 0)}
+	c := MakeCache[int, int](cmp.Compare[int], Config[int, int]{
+		TTL:      time.Minute,
+		MaxBytes: 100,
+		Now:      clk.Now,
+	})
+	for i := 0; i < 100; i++ {
+		c.Put(i, i)
+	}
+	s := c.Snapshot()
+	defer s.Close()
+	for i := 100; i < 150; i++ {
+		c.Put(i, i)
+	}
+	require.Equal(t, 100, c.Len())
+	require.Equal(t, 100, s.Len())
+	v, ok := s.Get(10)
+	require.True(t, ok)
+	require.Equal(t, 10, v)
+	_, ok = c.Get(10)
+	require.False(t, ok)
+	it := s.Iterator()
+	it.SeekGE(95)
+	require.Equal(t, []int{95, 96, 97, 98, 99}, func() (ks []int) {
+		for ; it.Valid(); it.Next() {
+			ks = append(ks, it.Cur())
+		}
+		return ks
+	}())
+	clk.advance(time.Hour)
+	_, ok = s.Get(10)
+	require.False(t, ok)
+}
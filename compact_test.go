@@ -0,0 +1,71 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package btree
+
+import (
+	"cmp"
+	"testing"
+
+	"github.com/ajwerner/btree/internal/abstract"
+)
+
+func TestCompact(t *testing.T) {
+	rng := newRand(t)
+	const N = 100000
+	m := MakeMap[int, int](cmp.Compare[int])
+	for _, i := range rng.Perm(N) {
+		m.Upsert(i, i)
+	}
+	for _, i := range rng.Perm(N)[:N*9/10] {
+		m.Delete(i)
+	}
+	clone := m.Clone()
+	defer clone.Reset()
+	ref := map[int]int{}
+	it := m.Iterator()
+	for it.First(); it.Valid(); it.Next() {
+		ref[it.Cur()] = it.Value()
+	}
+	for _, fill := range []float64{0, 0.75, 1} {
+		stats := m.Compact(fill)
+		minNodes := (len(ref) + abstract.MaxEntries - 1) / abstract.MaxEntries
+		if fill == 1 && (stats.BytesReclaimed() <= 0 ||
+			stats.NodesAfter > minNodes+minNodes/abstract.MaxEntries+1) {
+			t.Fatalf("expected densely packed tree, got %+v for %d entries", stats, len(ref))
+		}
+		checkMap(t, &m, ref)
+		checkMap(t, &clone, ref)
+	}
+	// The compacted tree must remain valid under further mutation.
+	for k := range ref {
+		m.Delete(k)
+	}
+	if m.Len() != 0 || m.Height() != 0 {
+		t.Fatalf("expected empty tree, got %d entries", m.Len())
+	}
+	for _, size := range []int{0, 1, abstract.MaxEntries, abstract.MaxEntries + 1, 20000} {
+		m := MakeSet(cmp.Compare[int])
+		for i := 0; i < size; i++ {
+			m.Upsert(i)
+		}
+		m.Compact(0.5)
+		for i := 0; i < size; i += 2 {
+			m.Delete(i)
+		}
+		if m.Len() != size/2 {
+			t.Fatalf("expected %d entries, got %d", size/2, m.Len())
+		}
+	}
+}
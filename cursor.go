@@ -0,0 +1,34 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package btree
+
+import "github.com/ajwerner/btree/internal/abstract"
+
+// Cursor records where a paginated scan of a Map left off. It records the
+// last key returned, so a scan resumes correctly after the Map is mutated.
+// The zero Cursor refers to the start of the Map. Cursors can be encoded as
+// opaque text with MarshalText, which encodes their keys as JSON.
+type Cursor[K any] = abstract.Cursor[K]
+
+// ErrInvalidCursor is returned when decoding a malformed Cursor, or when
+// encoding a Cursor whose key cannot be represented as JSON.
+var ErrInvalidCursor = abstract.ErrInvalidCursor
+
+// Page returns up to limit entries of the Map following the Cursor, in
+// order, along with a Cursor from which to resume the scan. The returned
+// Cursor is done once the scan reaches the end of the Map.
+func Page[K, V any](m *Map[K, V], c Cursor[K], limit int) ([]Entry[K, V], Cursor[K]) {
+	return abstract.Page(&m.Map, c, limit)
+}
@@ -0,0 +1,97 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package btree
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestPage(t *testing.T) {
+	m := MakeOrderedMap[string, int]()
+	for i := 0; i < 100; i++ {
+		m.Upsert(fmt.Sprintf("%03d", i*2), i)
+	}
+	var got []string
+	var c Cursor[string]
+	for pages := 0; !c.Done(); pages++ {
+		if pages > 100 {
+			t.Fatalf("scan did not terminate")
+		}
+		var entries []Entry[string, int]
+		entries, c = Page(&m, c, 7)
+		for _, e := range entries {
+			got = append(got, e.Key)
+		}
+		// Round-trip the cursor through its encoding.
+		text, err := c.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		c = Cursor[string]{}
+		if err := c.UnmarshalText(text); err != nil {
+			t.Fatal(err)
+		}
+		// Mutate the map between pages: delete the last key returned and
+		// insert keys both behind and ahead of the cursor.
+		if last, ok := c.After(); ok {
+			m.Delete(last)
+			m.Upsert(fmt.Sprintf("%03d", pages), -1)
+		}
+		m.Upsert(fmt.Sprintf("%03d", 199-pages*2), -1)
+	}
+	if !slices.IsSorted(got) || len(slices.Compact(slices.Clone(got))) != len(got) {
+		t.Fatalf("expected keys in increasing order, got %v", got)
+	}
+	for i := 0; i < 100; i++ {
+		if k := fmt.Sprintf("%03d", i*2); !slices.Contains(got, k) {
+			t.Fatalf("expected scan to include %s", k)
+		}
+	}
+	// A done cursor resumes with entries added after its key.
+	last, _ := c.After()
+	m.Upsert(last+"0", 1)
+	entries, next := Page(&m, c, 10)
+	if len(entries) != 1 || entries[0].Key != last+"0" || !next.Done() {
+		t.Fatalf("expected done cursor to resume at %s0, got %v", last, entries)
+	}
+	if entries, next = Page(&m, next, 10); len(entries) != 0 || !next.Done() {
+		t.Fatalf("expected no entries after the end, got %v", entries)
+	}
+	if err := c.UnmarshalText([]byte("!")); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+	// Keys which encode as an empty JSON object cannot be recorded.
+	type opaque struct{ k int }
+	om := MakeMap[opaque, int](func(a, b opaque) int { return cmp.Compare(a.k, b.k) })
+	om.Upsert(opaque{1}, 1)
+	om.Upsert(opaque{2}, 2)
+	_, oc := Page(&om, Cursor[opaque]{}, 1)
+	if _, err := oc.MarshalText(); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+	// Keys which JSON cannot represent cannot be recorded either.
+	fm := MakeOrderedMap[float64, int]()
+	fm.Upsert(math.NaN(), 1)
+	fm.Upsert(1, 2)
+	_, fc := Page(&fm, Cursor[float64]{}, 1)
+	if _, err := fc.MarshalText(); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+}
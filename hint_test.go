@@ -0,0 +1,95 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package btree
+
+import (
+	"cmp"
+	"testing"
+)
+
+func TestUpsertWithHint(t *testing.T) {
+	rng := newRand(t)
+	m := MakeMap[int, int](cmp.Compare[int])
+	ref := map[int]int{}
+	var h UpsertHint[int, int]
+	var clones []Map[int, int]
+	next := 0
+	for i := 0; i < 20000; i++ {
+		var k int
+		switch r := rng.Intn(10); {
+		case r < 7:
+			k = next
+			next++
+		case r < 9:
+			k = rng.Intn(next + 1)
+		default:
+			k = rng.Intn(next + 1)
+			m.Delete(k)
+			delete(ref, k)
+			continue
+		}
+		if rng.Intn(1000) == 0 {
+			clones = append(clones, m.Clone())
+		}
+		m.UpsertWithHint(k, i, &h)
+		ref[k] = i
+	}
+	for _, c := range clones {
+		c.Reset()
+	}
+	checkMap(t, &m, ref)
+
+	batch := make([]Entry[int, int], 1000)
+	for i := range batch {
+		batch[i] = Entry[int, int]{Key: next + i, Value: i}
+		ref[next+i] = i
+	}
+	m.UpsertBatch(batch)
+	checkMap(t, &m, ref)
+}
+
+func BenchmarkUpsertAppend(b *testing.B) {
+	const count = 1 << 16
+	entries := make([]Entry[int, int], count)
+	for i := range entries {
+		entries[i] = Entry[int, int]{Key: i, Value: i}
+	}
+	b.Run("upsert", func(b *testing.B) {
+		for i := 0; i < b.N; i += count {
+			m := MakeMap[int, int](cmp.Compare[int])
+			for _, e := range entries {
+				m.Upsert(e.Key, e.Value)
+			}
+			m.Reset()
+		}
+	})
+	b.Run("hint", func(b *testing.B) {
+		for i := 0; i < b.N; i += count {
+			m := MakeMap[int, int](cmp.Compare[int])
+			var h UpsertHint[int, int]
+			for _, e := range entries {
+				m.UpsertWithHint(e.Key, e.Value, &h)
+			}
+			m.Reset()
+		}
+	})
+	b.Run("batch", func(b *testing.B) {
+		for i := 0; i < b.N; i += count {
+			m := MakeMap[int, int](cmp.Compare[int])
+			m.UpsertBatch(entries)
+			m.Reset()
+		}
+	})
+}
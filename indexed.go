@@ -0,0 +1,315 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package btree
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+)
+
+// ErrUniqueViolation is returned when a record would share its key in a
+// unique index with another record.
+var ErrUniqueViolation = errors.New("btree: unique index violation")
+
+// ErrUnknownIndex is returned when an Index is used with an
+// IndexedCollection which does not have it.
+var ErrUnknownIndex = errors.New("btree: unknown index")
+
+// IndexedCollection is a collection of records of type T ordered by a
+// primary comparison function, along with any number of named secondary
+// indexes which order the records by other keys. Every mutation updates the
+// primary order and all of the indexes together.
+type IndexedCollection[T any] struct {
+	primary Map[T, struct{}]
+	cmp     func(T, T) int
+	opts    []Option
+	indexes []index[T]
+}
+
+// index is the type-erased interface of a secondary index.
+type index[T any] interface {
+	name() string
+
+	// conflicts returns true if the index is unique and holds a record other
+	// than rec, as determined by the primary order, with the same key.
+	conflicts(rec T) bool
+	insert(rec T)
+	delete(rec T)
+	clone() index[T]
+	reset()
+}
+
+// MakeIndexedCollection constructs a new IndexedCollection whose records
+// are identified and ordered by the provided comparison function. The
+// options apply to the primary order and to every index.
+func MakeIndexedCollection[T any](cmp func(T, T) int, opts ...Option) IndexedCollection[T] {
+	return IndexedCollection[T]{
+		primary: MakeMap[T, struct{}](cmp, opts...),
+		cmp:     cmp,
+		opts:    opts,
+	}
+}
+
+// Clone clones the IndexedCollection, including all of its indexes, lazily.
+// It does so in time proportional to the number of indexes.
+func (c *IndexedCollection[T]) Clone() IndexedCollection[T] {
+	clone := *c
+	clone.primary = c.primary.Clone()
+	clone.indexes = make([]index[T], len(c.indexes))
+	for i, idx := range c.indexes {
+		clone.indexes[i] = idx.clone()
+	}
+	return clone
+}
+
+// Reset removes all records from the IndexedCollection and its indexes. The
+// indexes remain registered.
+func (c *IndexedCollection[T]) Reset() {
+	c.primary.Reset()
+	for _, idx := range c.indexes {
+		idx.reset()
+	}
+}
+
+// Len returns the number of records in the IndexedCollection.
+func (c *IndexedCollection[T]) Len() int {
+	return c.primary.Len()
+}
+
+// Get returns the record which is equal to rec in the primary order, if any.
+func (c *IndexedCollection[T]) Get(rec T) (found T, ok bool) {
+	it := c.primary.Iterator()
+	if it.SeekGE(rec); !it.Valid() || c.cmp(it.Cur(), rec) != 0 {
+		return found, false
+	}
+	return it.Cur(), true
+}
+
+// Upsert inserts the record or replaces the record which is equal to it in
+// the primary order, updating every index. If the record would violate a
+// unique index, the collection is left unmodified and an error wrapping
+// ErrUniqueViolation is returned.
+func (c *IndexedCollection[T]) Upsert(rec T) (replaced T, overwrote bool, err error) {
+	for _, idx := range c.indexes {
+		if idx.conflicts(rec) {
+			return replaced, false, fmt.Errorf("%w: index %q", ErrUniqueViolation, idx.name())
+		}
+	}
+	// Remove the previous version of the record, whose keys in the indexes
+	// may differ.
+	replaced, overwrote = c.Delete(rec)
+	c.primary.Upsert(rec, struct{}{})
+	for _, idx := range c.indexes {
+		idx.insert(rec)
+	}
+	return replaced, overwrote, nil
+}
+
+// Delete removes the record which is equal to rec in the primary order from
+// the collection and every index. It returns the removed record, if any.
+func (c *IndexedCollection[T]) Delete(rec T) (removed T, found bool) {
+	if removed, _, found = c.primary.Delete(rec); found {
+		for _, idx := range c.indexes {
+			idx.delete(removed)
+		}
+	}
+	return removed, found
+}
+
+// Iterator constructs an iterator over the records of the IndexedCollection
+// in the primary order.
+func (c *IndexedCollection[T]) Iterator() SetIterator[T] {
+	return c.primary.Iterator()
+}
+
+// Index refers to a secondary index of an IndexedCollection whose keys are
+// of type K. It may be used with the IndexedCollection to which it was added
+// and with clones of it. Used with any other collection, it finds no records
+// unless that collection has an index of the same name and key type at the
+// same position.
+type Index[T, K any] struct {
+	pos  int
+	name string
+}
+
+// AddIndex registers a secondary index with the provided name which orders
+// records by the key extracted from them. Records with equal keys are
+// ordered by the primary order. If unique is true, no two records may share
+// a key. Existing records are added to the index; if they violate its
+// uniqueness, the index is not added and an error wrapping
+// ErrUniqueViolation is returned.
+func AddIndex[T, K any](
+	c *IndexedCollection[T], name string, key func(T) K, cmpK func(K, K) int, unique bool,
+) (Index[T, K], error) {
+	for _, idx := range c.indexes {
+		if idx.name() == name {
+			return Index[T, K]{}, fmt.Errorf("btree: index %q already exists", name)
+		}
+	}
+	s := &secondary[T, K]{
+		indexName: name,
+		key:       key,
+		cmpK:      cmpK,
+		cmpT:      c.cmp,
+		unique:    unique,
+	}
+	s.m = MakeMap[indexEntry[T, K], struct{}](s.compare, c.opts...)
+	it := c.primary.Iterator()
+	for it.First(); it.Valid(); it.Next() {
+		if s.conflicts(it.Cur()) {
+			s.reset()
+			return Index[T, K]{}, fmt.Errorf("%w: index %q", ErrUniqueViolation, name)
+		}
+		s.insert(it.Cur())
+	}
+	c.indexes = append(c.indexes, s)
+	return Index[T, K]{pos: len(c.indexes) - 1, name: name}, nil
+}
+
+// Name returns the name of the index.
+func (ix Index[T, K]) Name() string { return ix.name }
+
+// Get returns the first record in the index with the provided key, if any.
+// It returns false if the collection does not have the index.
+func (ix Index[T, K]) Get(c *IndexedCollection[T], k K) (rec T, ok bool) {
+	s, ok := ix.get(c)
+	if !ok {
+		return rec, false
+	}
+	it := s.m.Iterator()
+	if it.SeekGE(indexEntry[T, K]{k: k, bound: -1}); !it.Valid() || s.cmpK(it.Cur().k, k) != 0 {
+		return rec, false
+	}
+	return it.Cur().rec, true
+}
+
+// Iterator constructs an iterator over the records of the collection in the
+// order of the index. If the collection does not have the index, an error
+// wrapping ErrUnknownIndex is returned.
+func (ix Index[T, K]) Iterator(c *IndexedCollection[T]) (IndexIterator[T, K], error) {
+	s, ok := ix.get(c)
+	if !ok {
+		return IndexIterator[T, K]{}, fmt.Errorf("%w: index %q", ErrUnknownIndex, ix.name)
+	}
+	return IndexIterator[T, K]{it: s.m.Iterator()}, nil
+}
+
+// get returns the index of the collection to which ix refers, if the
+// collection has it. The Index may come from a different collection, so its
+// position, name and key type are all checked.
+func (ix Index[T, K]) get(c *IndexedCollection[T]) (*secondary[T, K], bool) {
+	if ix.pos < 0 || ix.pos >= len(c.indexes) || c.indexes[ix.pos].name() != ix.name {
+		return nil, false
+	}
+	s, ok := c.indexes[ix.pos].(*secondary[T, K])
+	return s, ok
+}
+
+// IndexIterator is an iterator over the records of an IndexedCollection in
+// the order of one of its indexes.
+type IndexIterator[T, K any] struct {
+	it MapIterator[indexEntry[T, K], struct{}]
+}
+
+// Reset marks the iterator as invalid.
+func (i *IndexIterator[T, K]) Reset() { i.it.Reset() }
+
+// First seeks to the first record in the index.
+func (i *IndexIterator[T, K]) First() { i.it.First() }
+
+// Last seeks to the last record in the index.
+func (i *IndexIterator[T, K]) Last() { i.it.Last() }
+
+// Next positions the iterator to the following record.
+func (i *IndexIterator[T, K]) Next() { i.it.Next() }
+
+// Prev positions the iterator to the previous record.
+func (i *IndexIterator[T, K]) Prev() { i.it.Prev() }
+
+// Valid returns whether the iterator is positioned at a valid position.
+func (i *IndexIterator[T, K]) Valid() bool { return i.it.Valid() }
+
+// SeekGE seeks to the first record with a key greater-than or equal to the
+// provided key.
+func (i *IndexIterator[T, K]) SeekGE(k K) { i.it.SeekGE(indexEntry[T, K]{k: k, bound: -1}) }
+
+// SeekLT seeks to the last record with a key less-than the provided key.
+func (i *IndexIterator[T, K]) SeekLT(k K) { i.it.SeekLT(indexEntry[T, K]{k: k, bound: -1}) }
+
+// Key returns the key of the record at the iterator's current position. It
+// is illegal to call Key if the iterator is not valid.
+func (i *IndexIterator[T, K]) Key() K { return i.it.Cur().k }
+
+// Cur returns the record at the iterator's current position. It is illegal
+// to call Cur if the iterator is not valid.
+func (i *IndexIterator[T, K]) Cur() T { return i.it.Cur().rec }
+
+// secondary is a secondary index of an IndexedCollection.
+type secondary[T, K any] struct {
+	indexName string
+	key       func(T) K
+	cmpK      func(K, K) int
+	cmpT      func(T, T) int
+	unique    bool
+	m         Map[indexEntry[T, K], struct{}]
+}
+
+// indexEntry is the key of a record in a secondary index. A search key with
+// a non-zero bound orders before (-1) or after (+1) every entry with its
+// key.
+type indexEntry[T, K any] struct {
+	k     K
+	rec   T
+	bound int8
+}
+
+func (s *secondary[T, K]) compare(a, b indexEntry[T, K]) int {
+	if c := s.cmpK(a.k, b.k); c != 0 {
+		return c
+	}
+	if a.bound != 0 || b.bound != 0 {
+		return cmp.Compare(a.bound, b.bound)
+	}
+	return s.cmpT(a.rec, b.rec)
+}
+
+func (s *secondary[T, K]) name() string { return s.indexName }
+
+func (s *secondary[T, K]) conflicts(rec T) bool {
+	if !s.unique {
+		return false
+	}
+	k := s.key(rec)
+	it := s.m.Iterator()
+	it.SeekGE(indexEntry[T, K]{k: k, bound: -1})
+	return it.Valid() && s.cmpK(it.Cur().k, k) == 0 && s.cmpT(it.Cur().rec, rec) != 0
+}
+
+func (s *secondary[T, K]) insert(rec T) {
+	s.m.Upsert(indexEntry[T, K]{k: s.key(rec), rec: rec}, struct{}{})
+}
+
+func (s *secondary[T, K]) delete(rec T) {
+	s.m.Delete(indexEntry[T, K]{k: s.key(rec), rec: rec})
+}
+
+func (s *secondary[T, K]) clone() index[T] {
+	c := *s
+	c.m = s.m.Clone()
+	return &c
+}
+
+func (s *secondary[T, K]) reset() { s.m.Reset() }
@@ -0,0 +1,128 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package btree
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestIndexedCollection(t *testing.T) {
+	type user struct {
+		id    int
+		email string
+		age   int
+	}
+	c := MakeIndexedCollection(func(a, b user) int { return cmp.Compare(a.id, b.id) })
+	for i := 0; i < 100; i++ {
+		if _, _, err := c.Upsert(user{id: i, email: fmt.Sprint(i, "@a"), age: i % 10}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	byEmail, err := AddIndex(&c, "email", func(u user) string { return u.email }, cmp.Compare[string], true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byAge, err := AddIndex(&c, "age", func(u user) int { return u.age }, cmp.Compare[int], false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AddIndex(&c, "age", func(u user) int { return u.age }, cmp.Compare[int], false); err == nil {
+		t.Fatalf("expected an error adding a duplicate index")
+	}
+	if _, err := AddIndex(&c, "unique-age", func(u user) int { return u.age }, cmp.Compare[int], true); !errors.Is(err, ErrUniqueViolation) {
+		t.Fatalf("expected a unique violation, got %v", err)
+	}
+	countAge := func(c *IndexedCollection[user], age int) (n int) {
+		it, err := byAge.Iterator(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for it.SeekGE(age); it.Valid() && it.Key() == age; it.Next() {
+			n++
+		}
+		return n
+	}
+	if n := countAge(&c, 3); n != 10 {
+		t.Fatalf("expected 10 users of age 3, got %d", n)
+	}
+
+	clone := c.Clone()
+	// Changing a user's email and age moves them in both indexes.
+	replaced, overwrote, err := c.Upsert(user{id: 3, email: "new@a", age: 4})
+	if err != nil || !overwrote || replaced.email != "3@a" {
+		t.Fatalf("unexpected result replacing user 3: %v %v %v", replaced, overwrote, err)
+	}
+	if _, ok := byEmail.Get(&c, "3@a"); ok {
+		t.Fatalf("expected old email to be removed")
+	}
+	if u, ok := byEmail.Get(&c, "new@a"); !ok || u.id != 3 {
+		t.Fatalf("expected to find user 3 by new email, got %v", u)
+	}
+	if n := countAge(&c, 3); n != 9 {
+		t.Fatalf("expected 9 users of age 3, got %d", n)
+	}
+
+	// A unique violation leaves every index unmodified.
+	_, _, err = c.Upsert(user{id: 200, email: "new@a", age: 5})
+	if !errors.Is(err, ErrUniqueViolation) {
+		t.Fatalf("expected a unique violation, got %v", err)
+	}
+	if _, ok := c.Get(user{id: 200}); ok || c.Len() != 100 || countAge(&c, 5) != 10 {
+		t.Fatalf("expected failed upsert to have no effect")
+	}
+
+	if removed, ok := c.Delete(user{id: 13}); !ok || removed.email != "13@a" {
+		t.Fatalf("unexpected result deleting user 13: %v", removed)
+	}
+	if _, ok := byEmail.Get(&c, "13@a"); ok || countAge(&c, 3) != 8 {
+		t.Fatalf("expected deleted user to be removed from the indexes")
+	}
+
+	// The clone is unaffected.
+	if u, ok := byEmail.Get(&clone, "3@a"); !ok || u.age != 3 {
+		t.Fatalf("expected clone to retain user 3, got %v", u)
+	}
+	if n := countAge(&clone, 3); n != 10 || clone.Len() != 100 {
+		t.Fatalf("expected clone to be unaffected, got %d users of age 3", n)
+	}
+
+	// An Index used with a collection which lacks it finds nothing, even if
+	// the collection has a different index at the same position.
+	other := MakeIndexedCollection(func(a, b user) int { return cmp.Compare(a.id, b.id) })
+	if _, _, err := other.Upsert(user{id: 1, email: "1@a", age: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := byEmail.Get(&other, "1@a"); ok {
+		t.Fatalf("expected no record from a collection without the index")
+	}
+	if _, err := byAge.Iterator(&other); !errors.Is(err, ErrUnknownIndex) {
+		t.Fatalf("expected an unknown index error, got %v", err)
+	}
+	if _, err := AddIndex(&other, "name", func(u user) string { return u.email }, cmp.Compare[string], false); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := byEmail.Get(&other, "1@a"); ok {
+		t.Fatalf("expected no record from an index with a different name")
+	}
+	if _, err := AddIndex(&other, "age", func(u user) string { return u.email }, cmp.Compare[string], false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := byAge.Iterator(&other); !errors.Is(err, ErrUnknownIndex) {
+		t.Fatalf("expected an unknown index error for a different key type, got %v", err)
+	}
+}
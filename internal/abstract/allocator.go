@@ -0,0 +1,158 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package abstract
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Allocator allocates and frees the nodes of the Maps which use it. An
+// Allocator may be shared by Maps of different types and is safe for
+// concurrent use.
+type Allocator struct {
+	kind allocatorKind
+
+	// pools holds a *nodePool[K, V, A] for each node type and value storage,
+	// keyed by a nodePoolKey[K, V, A].
+	pools sync.Map
+
+	// counters is nil for the default Allocator, which is shared by every
+	// Map not configured with an Allocator and so does not count its nodes.
+	counters *allocatorCounters
+}
+
+// allocatorCounters holds the counters reported by Allocator.Stats. Its
+// methods do nothing on a nil receiver.
+type allocatorCounters struct {
+	allocations, reuses, frees atomic.Int64
+}
+
+func (c *allocatorCounters) allocated() {
+	if c != nil {
+		c.allocations.Add(1)
+	}
+}
+
+func (c *allocatorCounters) reused() {
+	if c != nil {
+		c.reuses.Add(1)
+	}
+}
+
+func (c *allocatorCounters) freed(n int64) {
+	if c != nil {
+		c.frees.Add(n)
+	}
+}
+
+type allocatorKind int8
+
+const (
+	pooledAllocator allocatorKind = iota
+	heapAllocator
+	arenaAllocator
+)
+
+// AllocatorStats counts the nodes handled by an Allocator. The number of
+// nodes currently in use is Allocations + Reuses - Frees.
+type AllocatorStats struct {
+
+	// Allocations is the number of nodes allocated from the Go heap.
+	Allocations int64
+
+	// Reuses is the number of previously freed nodes which were reused.
+	Reuses int64
+
+	// Frees is the number of nodes returned to the Allocator.
+	Frees int64
+}
+
+// defaultAllocator is used by Maps which are not configured with an
+// Allocator. It keeps no counters.
+var defaultAllocator = &Allocator{kind: pooledAllocator}
+
+// NewPooledAllocator returns an Allocator which recycles freed nodes through
+// a sync.Pool. This is the behavior of Maps which are not configured with an
+// Allocator, except that those share a single pool per type.
+func NewPooledAllocator() *Allocator {
+	return newAllocator(pooledAllocator)
+}
+
+// NewHeapAllocator returns an Allocator which allocates every node from the
+// Go heap and leaves freed nodes to the garbage collector.
+func NewHeapAllocator() *Allocator {
+	return newAllocator(heapAllocator)
+}
+
+// NewArenaAllocator returns an Allocator which allocates nodes in slabs and
+// keeps freed nodes for reuse until Free is called.
+func NewArenaAllocator() *Allocator {
+	return newAllocator(arenaAllocator)
+}
+
+func newAllocator(kind allocatorKind) *Allocator {
+	return &Allocator{kind: kind, counters: &allocatorCounters{}}
+}
+
+// Stats returns the counters of the Allocator.
+func (a *Allocator) Stats() AllocatorStats {
+	if a.counters == nil {
+		return AllocatorStats{}
+	}
+	return AllocatorStats{
+		Allocations: a.counters.allocations.Load(),
+		Reuses:      a.counters.reuses.Load(),
+		Frees:       a.counters.frees.Load(),
+	}
+}
+
+// Free releases all of the memory held by an arena Allocator at once,
+// including nodes still in use, which are counted as freed. Maps using the
+// Allocator must not be used after Free is called; doing so is memory safe
+// but may leak the nodes they reference. Free has no effect on other
+// Allocators.
+func (a *Allocator) Free() {
+	if a.kind != arenaAllocator {
+		return
+	}
+	a.pools.Range(func(_, v any) bool {
+		v.(interface{ free() }).free()
+		return true
+	})
+	s := a.Stats()
+	a.counters.freed(s.Allocations + s.Reuses - s.Frees)
+}
+
+// Option configures a Map.
+type Option func(*options)
+
+type options struct {
+	alloc     *Allocator
+	outOfLine bool
+}
+
+// WithAllocator configures a Map to allocate its nodes with the provided
+// Allocator. Clones of the Map share its Allocator.
+func WithAllocator(a *Allocator) Option {
+	return func(o *options) { o.alloc = a }
+}
+
+// WithOutOfLineValues configures whether a Map stores each of its values in
+// a separate allocation rather than in its nodes. By default, values are
+// stored in the nodes. Clones of the Map store values in the same way.
+func WithOutOfLineValues(enabled bool) Option {
+	return func(o *options) { o.outOfLine = enabled }
+}
@@ -40,15 +40,21 @@ const (
 // Write operations are not safe for concurrent mutation by multiple
 // goroutines, but Read operations are.
 type Map[K, V, A any] struct {
-	root   *Node[K, V, A]
-	length int
-	cfg    config[K, V, A]
+	// version identifies the contents of the Map for Position. It is zero
+	// until a Position is taken and is reset by every mutation. It is kept
+	// first so that it is 64-bit aligned for atomic access.
+	version uint64
+	root    *Node[K, V, A]
+	length  int
+	cfg     config[K, V, A]
 }
 
 // MakeMap constructs a new Map.
-func MakeMap[K, V, A any](cmp func(K, K) int, up Updater[K, V, A]) Map[K, V, A] {
+func MakeMap[K, V, A any](
+	cmp func(K, K) int, up Updater[K, V, A], opts ...Option,
+) Map[K, V, A] {
 	return Map[K, V, A]{
-		cfg: makeConfig(cmp, up),
+		cfg: makeConfig(cmp, up, opts),
 	}
 }
 
@@ -57,6 +63,7 @@ func MakeMap[K, V, A any](cmp func(K, K) int, up Updater[K, V, A]) Map[K, V, A]
 // letting a AugBTree be GCed is safe in that it won't cause a memory leak,
 // but it will prevent AugBTree nodes from being efficiently re-used.
 func (t *Map[K, V, A]) Reset() {
+	t.modified()
 	if t.root != nil {
 		t.root.decRef(t.cfg.np, true /* recursive */)
 		t.root = nil
@@ -91,6 +98,7 @@ func (t *Map[K, V, A]) Delete(k K) (removedK K, v V, found bool) {
 	if t.root == nil || t.root.count == 0 {
 		return removedK, v, false
 	}
+	t.modified()
 	if removedK, v, found, _ = mut(t.cfg.np, &t.root).remove(&t.cfg, k); found {
 		t.length--
 	}
@@ -109,22 +117,33 @@ func (t *Map[K, V, A]) Delete(k K) (removedK K, v V, found bool) {
 // Upsert adds the given item to the tree. If an item in the tree already equals
 // the given one, it is replaced with the new item.
 func (t *Map[K, V, A]) Upsert(item K, value V) (replacedK K, replacedV V, replaced bool) {
+	return t.upsert(item, value, nil)
+}
+
+// upsert implements Upsert. If path is non-nil, the nodes visited on the way
+// to the node into which the item was written are pushed onto it.
+func (t *Map[K, V, A]) upsert(
+	item K, value V, path *iterStack[K, V, A],
+) (replacedK K, replacedV V, replaced bool) {
+	t.modified()
 	if t.root == nil {
-		t.root = t.cfg.np.getLeafNode()
+		t.root = t.cfg.np.getSmallLeafNode()
+	} else if t.root.IsLeaf() && t.root.isSmall() && t.root.full() {
+		t.root = t.root.grow(t.cfg.np)
 	} else if t.root.count >= MaxEntries {
 		splitLaK, splitLaV, splitNode := mut(t.cfg.np, &t.root).
 			split(&t.cfg, MaxEntries/2)
 		newRoot := t.cfg.np.getInteriorNode()
 		newRoot.count = 1
-		newRoot.keys[0] = splitLaK
-		newRoot.values[0] = splitLaV
+		newRoot.keys()[0] = splitLaK
+		newRoot.setValue(0, splitLaV)
 		newRoot.children[0] = t.root
 		newRoot.children[1] = splitNode
 		newRoot.update(&t.cfg.Config)
 		t.root = newRoot
 	}
 	replacedK, replacedV, replaced, _ = mut(t.cfg.np, &t.root).
-		insert(&t.cfg, item, value)
+		insert(&t.cfg, item, value, path)
 	if !replaced {
 		t.length++
 	}
@@ -176,6 +195,6 @@ func (t *Map[K, V, A]) String() string {
 		return ";"
 	}
 	var b strings.Builder
-	t.root.writeString(&b)
+	t.root.writeString(&b, t.cfg.bplus)
 	return b.String()
 }
@@ -0,0 +1,256 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package abstract
+
+import "cmp"
+
+// MakeBPlusMap constructs a new Map which stores all of its entries in leaf
+// nodes, in the manner of a B+tree. The keys of interior nodes are separators:
+// every key in the subtree at children[i] is less than keys[i], and every key
+// in the subtree at children[i+1] is greater than or equal to it. Separators
+// are copies of keys which may since have been removed, and interior values
+// are unused.
+//
+// Iteration only visits leaves, moving between adjacent leaves through the
+// iterator's stack rather than through pointers stored in the nodes, which
+// would be incompatible with copy-on-write. This makes range scans access
+// memory sequentially at the cost of storing the separators.
+//
+// An Updater used with this layout must account for interior keys being
+// separators rather than entries.
+func MakeBPlusMap[K, V, A any](
+	cmp func(K, K) int, up Updater[K, V, A], opts ...Option,
+) Map[K, V, A] {
+	m := MakeMap[K, V, A](cmp, up, opts...)
+	m.cfg.bplus = true
+	return m
+}
+
+// MakeOrderedBPlusMap is like MakeBPlusMap but searches as MakeOrderedMap.
+func MakeOrderedBPlusMap[K cmp.Ordered, V, A any](
+	up Updater[K, V, A], opts ...Option,
+) Map[K, V, A] {
+	m := MakeOrderedMap[K, V, A](up, opts...)
+	m.cfg.bplus = true
+	return m
+}
+
+// childIndex returns the index of the child of the interior node n whose
+// subtree may contain the item.
+func (c *config[K, V, A]) childIndex(n *Node[K, V, A], item K) int {
+	i, found := c.find(n, item)
+	if found {
+		i++
+	}
+	return i
+}
+
+// splitLeaf splits the leaf n such that the entries from index i onwards are
+// moved into a new leaf. It returns the first key of the new leaf, which
+// separates the two leaves in their parent, along with the new leaf.
+func (n *Node[K, V, A]) splitLeaf(cfg *config[K, V, A], i int) (K, V, *Node[K, V, A]) {
+	next := cfg.np.getLeafNode()
+	next.count = n.count - int16(i)
+	copy(next.keys(), n.keys()[i:n.count])
+	next.copyValues(0, n, i, int(n.count))
+	clear(n.keys()[i:n.count])
+	n.clearValues(i, int(n.count))
+	n.count = int16(i)
+	next.update(&cfg.Config)
+	n.update(&cfg.Config)
+	var rV V
+	return next.keys()[0], rV, next
+}
+
+// insertBPlus is like insert for trees which store all entries in leaves.
+func (n *Node[K, V, A]) insertBPlus(
+	cfg *config[K, V, A], item K, value V, path *iterStack[K, V, A],
+) (replacedK K, replacedV V, replaced, newBound bool) {
+	if n.IsLeaf() {
+		i, found := cfg.find(n, item)
+		if path != nil {
+			path.push(iterFrame[K, V, A]{node: n, pos: int16(i)})
+		}
+		if found {
+			replacedK, replacedV = n.keys()[i], n.value(i)
+			n.keys()[i] = item
+			n.setValue(i, value)
+			return replacedK, replacedV, true, false
+		}
+		n.insertAt(i, item, value, nil)
+		return replacedK, replacedV, false, n.updateOn(&cfg.Config, Insertion, item, nil)
+	}
+	i := cfg.childIndex(n, item)
+	if n.children[i].count >= MaxEntries {
+		splitK, splitV, splitNode := mut(cfg.np, &n.children[i]).
+			split(cfg, MaxEntries/2)
+		n.insertAt(i, splitK, splitV, splitNode)
+		if cfg.cmp(item, n.keys()[i]) >= 0 {
+			i++ // we want second split node
+		}
+	}
+	if path != nil {
+		path.push(iterFrame[K, V, A]{node: n, pos: int16(i)})
+	}
+	replacedK, replacedV, replaced, newBound =
+		mut(cfg.np, &n.children[i]).insertBPlus(cfg, item, value, path)
+	if newBound {
+		newBound = n.updateOn(&cfg.Config, Insertion, item, nil)
+	}
+	return replacedK, replacedV, replaced, newBound
+}
+
+// removeBPlus is like remove for trees which store all entries in leaves.
+func (n *Node[K, V, A]) removeBPlus(
+	cfg *config[K, V, A], item K,
+) (outK K, outV V, found, newBound bool) {
+	if n.IsLeaf() {
+		i, found := cfg.find(n, item)
+		if !found {
+			return outK, outV, false, false
+		}
+		outK, outV, _ = n.removeAt(i)
+		return outK, outV, true, n.updateOn(&cfg.Config, Removal, outK, nil)
+	}
+	i := cfg.childIndex(n, item)
+	if n.children[i].count <= MinEntries {
+		// Child not large enough to remove from.
+		if n.children[i].IsLeaf() {
+			n.rebalanceOrMergeLeaves(cfg, i)
+		} else {
+			n.rebalanceOrMerge(cfg, i)
+		}
+		return n.removeBPlus(cfg, item) // redo
+	}
+	// A separator equal to the item is left in place; it continues to
+	// separate the children correctly.
+	outK, outV, found, newBound = mut(cfg.np, &n.children[i]).removeBPlus(cfg, item)
+	if newBound {
+		newBound = n.updateOn(&cfg.Config, Removal, outK, nil)
+	}
+	return outK, outV, found, newBound
+}
+
+// rebalanceOrMergeLeaves is like rebalanceOrMerge for children which are
+// leaves of a tree which stores all entries in leaves. Entries move directly
+// between the leaves and the separator in n is replaced rather than rotated.
+func (n *Node[K, V, A]) rebalanceOrMergeLeaves(cfg *config[K, V, A], i int) {
+	switch {
+	case i > 0 && n.children[i-1].count > MinEntries:
+		// Rebalance from left sibling.
+		left := mut(cfg.np, &n.children[i-1])
+		child := mut(cfg.np, &n.children[i])
+		k, v, _ := left.popBack()
+		child.pushFront(k, v, nil)
+		n.keys()[i-1] = k
+		left.updateOn(&cfg.Config, Removal, k, nil)
+		child.updateOn(&cfg.Config, Insertion, k, nil)
+
+	case i < int(n.count) && n.children[i+1].count > MinEntries:
+		// Rebalance from right sibling.
+		right := mut(cfg.np, &n.children[i+1])
+		child := mut(cfg.np, &n.children[i])
+		k, v, _ := right.popFront()
+		child.pushBack(k, v, nil)
+		n.keys()[i] = right.keys()[0]
+		right.updateOn(&cfg.Config, Removal, k, nil)
+		child.updateOn(&cfg.Config, Insertion, k, nil)
+
+	default:
+		// Merge with either the left or right sibling, discarding the
+		// separator between them.
+		if i >= int(n.count) {
+			i = int(n.count - 1)
+		}
+		child := mut(cfg.np, &n.children[i])
+		_ = mut(cfg.np, &n.children[i+1])
+		_, _, mergeChild := n.removeAt(i)
+		copy(child.keys()[child.count:], mergeChild.keys()[:mergeChild.count])
+		child.copyValues(int(child.count), mergeChild, 0, int(mergeChild.count))
+		child.count += mergeChild.count
+		child.update(&cfg.Config)
+		mergeChild.decRef(cfg.np, false /* recursive */)
+	}
+}
+
+// seekGEBPlus is like SeekGE for trees which store all entries in leaves.
+func (i *Iterator[K, V, A]) seekGEBPlus(key K) {
+	i.Reset()
+	if i.node == nil {
+		return
+	}
+	ll := i.lowLevel()
+	for !i.node.IsLeaf() {
+		i.pos = int16(i.r.cfg.childIndex(i.node, key))
+		ll.Descend()
+	}
+	pos, _ := i.r.cfg.find(i.node, key)
+	// Position the iterator before the key so that Next advances to the
+	// following leaf if the key is beyond the end of this one.
+	i.pos = int16(pos) - 1
+	i.Next()
+}
+
+// seekLTBPlus is like SeekLT for trees which store all entries in leaves.
+func (i *Iterator[K, V, A]) seekLTBPlus(key K) {
+	i.Reset()
+	if i.node == nil {
+		return
+	}
+	ll := i.lowLevel()
+	for !i.node.IsLeaf() {
+		i.pos = int16(i.r.cfg.childIndex(i.node, key))
+		ll.Descend()
+	}
+	pos, _ := i.r.cfg.find(i.node, key)
+	i.pos = int16(pos)
+	i.Prev()
+}
+
+// nextLeaf moves the iterator from the end of a leaf to the first entry of
+// the following leaf. It ascends to the nearest ancestor with a following
+// child and descends to the first entry of that child's subtree.
+func (i *Iterator[K, V, A]) nextLeaf() {
+	ll := i.lowLevel()
+	for i.s.len() > 0 {
+		ll.Ascend()
+		if i.pos < i.node.count {
+			i.pos++
+			for !i.node.IsLeaf() {
+				ll.Descend()
+			}
+			return
+		}
+	}
+}
+
+// prevLeaf moves the iterator from the start of a leaf to the last entry of
+// the preceding leaf.
+func (i *Iterator[K, V, A]) prevLeaf() {
+	ll := i.lowLevel()
+	for i.s.len() > 0 {
+		ll.Ascend()
+		if i.pos > 0 {
+			i.pos--
+			for !i.node.IsLeaf() {
+				ll.Descend()
+				i.pos = i.node.count
+			}
+			i.pos = i.node.count - 1
+			return
+		}
+	}
+	i.pos = -1
+}
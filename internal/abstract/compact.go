@@ -0,0 +1,223 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package abstract
+
+import (
+	"math"
+	"unsafe"
+)
+
+// CompactionStats describes the effect of a call to Compact.
+type CompactionStats struct {
+
+	// NodesBefore and NodesAfter are the number of nodes in the tree before
+	// and after compaction.
+	NodesBefore, NodesAfter int
+
+	// BytesBefore and BytesAfter are the number of bytes occupied by the nodes
+	// in the tree before and after compaction.
+	BytesBefore, BytesAfter int
+}
+
+// BytesReclaimed returns the reduction in the number of bytes occupied by
+// the tree's nodes. Nodes which were shared with a clone of the tree remain
+// in use by that clone.
+func (s CompactionStats) BytesReclaimed() int {
+	return s.BytesBefore - s.BytesAfter
+}
+
+// Compact rewrites the tree into densely packed nodes. The targetFill is the
+// desired fraction of MaxEntries to store in each node; it is clamped such
+// that the resulting nodes are always valid. The previous nodes are released:
+// those exclusively owned by this tree are returned to the pool, while those
+// shared with clones remain untouched. Augmentations are recomputed for all
+// of the new nodes.
+func (t *Map[K, V, A]) Compact(targetFill float64) (stats CompactionStats) {
+	stats.NodesBefore, stats.BytesBefore = t.root.footprint(t.cfg.np)
+	if t.root == nil {
+		return stats
+	}
+	if t.length == 0 {
+		t.Reset()
+		return stats
+	}
+	t.modified()
+	b := makeBuilder(&t.cfg, t.length, targetFill)
+	it := t.Iterator()
+	it.First()
+	root := b.build(&it, t.length, b.height(t.length), true /* isRoot */)
+	t.root.decRef(t.cfg.np, true /* recursive */)
+	t.root = root
+	stats.NodesAfter, stats.BytesAfter = t.root.footprint(t.cfg.np)
+	return stats
+}
+
+// footprint returns the number of nodes in the subtree rooted at n and the
+// number of bytes they occupy, including any values stored out of line.
+func (n *Node[K, V, A]) footprint(np *nodePool[K, V, A]) (nodes, bytes int) {
+	if n == nil {
+		return 0, 0
+	}
+	nodes, bytes = 1, n.size(np)
+	if n.IsLeaf() {
+		return nodes, bytes
+	}
+	for i := int16(0); i <= n.count; i++ {
+		cn, cb := n.children[i].footprint(np)
+		nodes, bytes = nodes+cn, bytes+cb
+	}
+	return nodes, bytes
+}
+
+// builder constructs a tree bottom-up from a sorted sequence of entries.
+//
+// For each height h, it tracks the minimum and maximum number of entries
+// which can be stored in a valid non-root subtree of that height as well as
+// the number of entries stored in a subtree of that height if every node is
+// filled to the target. When constructing a node, the number of children is
+// chosen to approximate the target while keeping each child within the
+// bounds for its height.
+//
+// In a tree which stores all entries in leaves, interior keys are copies of
+// the first key of the following child rather than entries of their own, so
+// sep is 0 rather than 1.
+type builder[K, V, A any] struct {
+	cfg                     *config[K, V, A]
+	sep                     int
+	minSize, maxSize, fills []int
+}
+
+func makeBuilder[K, V, A any](
+	cfg *config[K, V, A], n int, targetFill float64,
+) builder[K, V, A] {
+	target := int(math.Round(targetFill * MaxEntries))
+	target = min(max(target, MinEntries), MaxEntries)
+	b := builder[K, V, A]{cfg: cfg, sep: 1}
+	if cfg.bplus {
+		b.sep = 0
+	}
+	// Index 0 is unused so that slices can be indexed by height.
+	b.minSize, b.maxSize, b.fills = []int{0}, []int{0}, []int{0}
+	grow := func(sizes []int, perNode int) []int {
+		prev := sizes[len(sizes)-1]
+		if len(sizes) == 1 {
+			return append(sizes, perNode)
+		}
+		return append(sizes, saturatingAdd(b.sep*perNode, saturatingMul(perNode+1, prev)))
+	}
+	for b.maxSize[len(b.maxSize)-1] < n {
+		b.minSize = grow(b.minSize, MinEntries)
+		b.maxSize = grow(b.maxSize, MaxEntries)
+		b.fills = grow(b.fills, target)
+	}
+	return b
+}
+
+// height returns the minimum height of a tree containing n entries.
+func (b *builder[K, V, A]) height(n int) int {
+	h := 1
+	for b.maxSize[h] < n {
+		h++
+	}
+	return h
+}
+
+// build constructs a subtree of height h containing the next n entries from
+// the iterator.
+func (b *builder[K, V, A]) build(
+	it *Iterator[K, V, A], n, h int, isRoot bool,
+) *Node[K, V, A] {
+	if h == 1 {
+		var nd *Node[K, V, A]
+		if isRoot && n <= smallLeafEntries {
+			nd = b.cfg.np.getSmallLeafNode()
+		} else {
+			nd = b.cfg.np.getLeafNode()
+		}
+		for i := 0; i < n; i++ {
+			nd.keys()[i] = it.Cur()
+			nd.setValue(i, it.Value())
+			it.Next()
+		}
+		nd.count = int16(n)
+		nd.update(&b.cfg.Config)
+		return nd
+	}
+	sub, sep := h-1, b.sep
+	children := ceilDiv(n+sep, b.fills[sub]+sep)
+	if isRoot {
+		children = max(children, 2)
+	} else {
+		children = max(children, MinEntries+1)
+	}
+	children = max(children, ceilDiv(n+sep, b.maxSize[sub]+sep))
+	children = min(children, (n+sep)/(b.minSize[sub]+sep), MaxEntries+1)
+	per, rem := (n-(children-1)*sep)/children, (n-(children-1)*sep)%children
+	nd := b.cfg.np.getInteriorNode()
+	for i := 0; i < children; i++ {
+		size := per
+		if i < rem {
+			size++
+		}
+		if i > 0 && sep == 0 {
+			nd.keys()[i-1] = it.Cur()
+		}
+		nd.children[i] = b.build(it, size, sub, false /* isRoot */)
+		if i < children-1 && sep == 1 {
+			nd.keys()[i] = it.Cur()
+			nd.setValue(i, it.Value())
+			it.Next()
+		}
+	}
+	nd.count = int16(children - 1)
+	nd.update(&b.cfg.Config)
+	return nd
+}
+
+// size returns the number of bytes occupied by n and its storage.
+func (n *Node[K, V, A]) size(np *nodePool[K, V, A]) int {
+	var size int
+	switch {
+	case !n.IsLeaf():
+		size = np.sizes[interiorKind]
+	case n.isSmall():
+		size = np.sizes[smallLeafKind]
+	default:
+		size = np.sizes[leafKind]
+	}
+	if n.outOfLine {
+		var v V
+		size += int(n.count) * int(unsafe.Sizeof(v))
+	}
+	return size
+}
+
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
+
+func saturatingAdd(a, b int) int {
+	if a > math.MaxInt-b {
+		return math.MaxInt
+	}
+	return a + b
+}
+
+func saturatingMul(a, b int) int {
+	if b != 0 && a > math.MaxInt/b {
+		return math.MaxInt
+	}
+	return a * b
+}
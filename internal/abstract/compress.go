@@ -0,0 +1,43 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package abstract
+
+// Compressor customizes how the keys of each node are searched and stored.
+// It allows keys to share storage with the other keys in their node, for
+// example by factoring out a common prefix.
+type Compressor[K any] interface {
+
+	// Search returns the index where the item should be inserted into the
+	// sorted keys and whether it already exists at that index. It must be
+	// consistent with the Map's comparison function.
+	Search(keys []K, item K) (index int, found bool)
+
+	// Compress is called with the keys of a node after keys have been added
+	// to or removed from it. It may replace any of the keys with one which
+	// compares equal to it. Keys may move between nodes without being passed
+	// to Compress, so each key must remain meaningful on its own.
+	Compress(keys []K)
+}
+
+// MakeCompressedMap constructs a new Map which uses the provided Compressor
+// to search and store the keys of each node.
+func MakeCompressedMap[K, V, A any](
+	cmp func(K, K) int, c Compressor[K], up Updater[K, V, A], opts ...Option,
+) Map[K, V, A] {
+	m := MakeMap[K, V, A](cmp, up, opts...)
+	m.cfg.search = c.Search
+	m.cfg.compress = c.Compress
+	return m
+}
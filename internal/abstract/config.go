@@ -23,6 +23,10 @@ type Config[K, V, A any] struct {
 	Updater Updater[K, V, A]
 
 	cmp func(K, K) int
+
+	// compress, if non-nil, is called with the keys of a node whenever they
+	// are modified. See MakeCompressedMap.
+	compress func(keys []K)
 }
 
 // Updater is used to update the augmentation of the node when the subtree
@@ -86,13 +90,33 @@ func (c *Config[K, V, A]) Compare(a, b K) int { return c.cmp(a, b) }
 type config[K, V, A any] struct {
 	Config[K, V, A]
 	np *nodePool[K, V, A]
+
+	// search, if non-nil, is used in place of cmp to locate keys within a
+	// node. See MakeOrderedMap.
+	search func(keys []K, item K) (index int, found bool)
+
+	// bplus is true if all entries are stored in leaves. See MakeBPlusMap.
+	bplus bool
+}
+
+// find returns the index where the given item should be inserted into n and
+// whether the item already exists at that index.
+func (c *config[K, V, A]) find(n *Node[K, V, A], item K) (index int, found bool) {
+	if c.search != nil {
+		return c.search(n.keys()[:n.count], item)
+	}
+	return n.find(c.cmp, item)
 }
 
 func makeConfig[K, V, A any](
-	cmp func(K, K) int, up Updater[K, V, A],
+	cmp func(K, K) int, up Updater[K, V, A], opts []Option,
 ) (c config[K, V, A]) {
+	o := options{alloc: defaultAllocator}
+	for _, opt := range opts {
+		opt(&o)
+	}
 	c.Updater = up
 	c.cmp = cmp
-	c.np = getNodePool[K, V, A]()
+	c.np = getNodePool[K, V, A](o.alloc, o.outOfLine)
 	return c
 }
@@ -0,0 +1,178 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package abstract
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidCursor is returned when decoding a malformed Cursor, or when
+// encoding a Cursor whose key cannot be represented as JSON.
+var ErrInvalidCursor = errors.New("btree: invalid cursor")
+
+// Cursor records where a paginated scan of a Map left off. Rather than a
+// position in a particular version of the tree, it records the last key
+// returned, so a scan resumes correctly after the Map is mutated. The zero
+// Cursor refers to the start of the Map.
+//
+// A Cursor can be encoded as opaque text with MarshalText, which encodes
+// its key as JSON. Keys which JSON does not represent, such as structs
+// without exported fields, cannot be encoded.
+type Cursor[K any] struct {
+	key    K
+	hasKey bool
+
+	// rank is the rank of the entry following the cursor, if known.
+	rank    int
+	hasRank bool
+
+	done bool
+}
+
+// CursorAt returns a Cursor positioned before the entry with the provided
+// rank.
+func CursorAt[K any](rank int) Cursor[K] {
+	return Cursor[K]{rank: rank, hasRank: true}
+}
+
+// Done returns true if the scan which returned the Cursor reached the end
+// of the Map at the time. Resuming a done Cursor returns any entries which
+// have since been added after its key.
+func (c Cursor[K]) Done() bool { return c.done }
+
+// After returns the last key returned by the scan, if any.
+func (c Cursor[K]) After() (k K, ok bool) { return c.key, c.hasKey }
+
+// Rank returns the rank of the entry which follows the Cursor, if it is
+// known. It is known only for cursors returned by scans which track ranks
+// and for those constructed with CursorAt. The rank reflects the version
+// of the Map at the time the Cursor was created.
+func (c Cursor[K]) Rank() (rank int, ok bool) { return c.rank, c.hasRank }
+
+// encodedCursor is the JSON encoding of a Cursor.
+type encodedCursor[K any] struct {
+	Key  *K   `json:"k,omitempty"`
+	Rank *int `json:"r,omitempty"`
+	Done bool `json:"d,omitempty"`
+}
+
+// emptyObject is the JSON encoding of a value with no exported fields.
+var emptyObject = []byte("{}")
+
+// MarshalText encodes the Cursor as opaque, URL-safe text. It returns an
+// error wrapping ErrInvalidCursor if the key cannot be encoded as JSON or is
+// encoded as an empty JSON object, which would not decode to the same key.
+func (c Cursor[K]) MarshalText() ([]byte, error) {
+	var e encodedCursor[K]
+	if c.hasKey {
+		buf, err := json.Marshal(c.key)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+		}
+		if bytes.Equal(buf, emptyObject) {
+			return nil, fmt.Errorf(
+				"%w: key of type %T encodes as an empty JSON object",
+				ErrInvalidCursor, c.key,
+			)
+		}
+		e.Key = &c.key
+	}
+	if c.hasRank {
+		e.Rank = &c.rank
+	}
+	e.Done = c.done
+	buf, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	return base64.RawURLEncoding.AppendEncode(nil, buf), nil
+}
+
+// UnmarshalText decodes a Cursor encoded by MarshalText.
+func (c *Cursor[K]) UnmarshalText(text []byte) error {
+	buf, err := base64.RawURLEncoding.AppendDecode(nil, text)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	var e encodedCursor[K]
+	if err := json.Unmarshal(buf, &e); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if e.Rank != nil && *e.Rank < 0 {
+		return fmt.Errorf("%w: negative rank", ErrInvalidCursor)
+	}
+	*c = Cursor[K]{done: e.Done}
+	if e.Key != nil {
+		c.key, c.hasKey = *e.Key, true
+	}
+	if e.Rank != nil {
+		c.rank, c.hasRank = *e.Rank, true
+	}
+	return nil
+}
+
+// Page returns up to limit entries of the Map following the Cursor, along
+// with a Cursor from which to resume the scan.
+func Page[K, V, A any](t *Map[K, V, A], c Cursor[K], limit int) ([]Entry[K, V], Cursor[K]) {
+	it := t.Iterator()
+	SeekCursor(&it, c)
+	return FillPage(&it, c, limit, nil)
+}
+
+// SeekCursor positions the iterator at the first entry following the last
+// key returned by the scan which produced the Cursor or, if there is no
+// such key, at the first entry.
+func SeekCursor[K, V, A any](it *Iterator[K, V, A], c Cursor[K]) {
+	if !c.hasKey {
+		it.First()
+		return
+	}
+	it.SeekGE(c.key)
+	if it.Valid() && it.Compare(it.Cur(), c.key) == 0 {
+		it.Next()
+	}
+}
+
+// FillPage returns up to limit entries starting at the iterator's current
+// position, along with a Cursor from which to resume the scan. If rank is
+// non-nil, it is used to record the rank of the following entry in the
+// Cursor. If limit is not positive, no entries are returned and the Cursor
+// is returned unmodified.
+func FillPage[K, V, A any](
+	it *Iterator[K, V, A], c Cursor[K], limit int, rank func() int,
+) ([]Entry[K, V], Cursor[K]) {
+	if limit <= 0 {
+		return nil, c
+	}
+	var entries []Entry[K, V]
+	for ; it.Valid() && len(entries) < limit; it.Next() {
+		entries = append(entries, Entry[K, V]{Key: it.Cur(), Value: it.Value()})
+	}
+	next := c
+	if len(entries) > 0 {
+		next = Cursor[K]{key: entries[len(entries)-1].Key, hasKey: true}
+	}
+	switch {
+	case !it.Valid():
+		next.done = true
+	case rank != nil:
+		next.rank, next.hasRank = rank(), true
+	}
+	return entries, next
+}
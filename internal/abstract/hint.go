@@ -0,0 +1,109 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package abstract
+
+import "sync/atomic"
+
+// Entry is a key-value pair.
+type Entry[K, V any] struct {
+	Key   K
+	Value V
+}
+
+// UpsertHint remembers the path to the leaf most recently written by
+// UpsertWithHint. When a subsequent key falls within the bounds of that leaf,
+// the insertion proceeds directly in the leaf rather than descending from the
+// root. The zero value is ready to use. A hint may be used with any Map; if
+// it does not describe the Map's current structure, it is ignored.
+type UpsertHint[K, V, A any] struct {
+	path iterStack[K, V, A]
+}
+
+// UpsertWithHint is like Upsert but uses and updates the provided hint to
+// accelerate insertions of keys which are near each other, such as keys
+// inserted in increasing order.
+func (t *Map[K, V, A]) UpsertWithHint(
+	item K, value V, h *UpsertHint[K, V, A],
+) (replacedK K, replacedV V, replaced bool) {
+	if replacedK, replacedV, replaced, ok := t.upsertHinted(item, value, h); ok {
+		return replacedK, replacedV, replaced
+	}
+	h.path.reset()
+	return t.upsert(item, value, &h.path)
+}
+
+// UpsertBatch upserts each of the provided entries. It is most efficient when
+// the entries are sorted by key.
+func (t *Map[K, V, A]) UpsertBatch(entries []Entry[K, V]) {
+	var h UpsertHint[K, V, A]
+	for i := range entries {
+		t.UpsertWithHint(entries[i].Key, entries[i].Value, &h)
+	}
+}
+
+// upsertHinted attempts to write the item directly into the leaf recorded in
+// the hint. It returns false if the hint does not describe a path from the
+// current root to an exclusively owned leaf with room for the item whose
+// bounds contain the item.
+func (t *Map[K, V, A]) upsertHinted(
+	item K, value V, h *UpsertHint[K, V, A],
+) (replacedK K, replacedV V, replaced, ok bool) {
+	depth := h.path.len()
+	if depth == 0 {
+		return replacedK, replacedV, false, false
+	}
+	// Validate the path against the current tree, tracking the tightest
+	// bounds implied by the separators in the ancestors.
+	var lo, hi *K
+	n := t.root
+	for j := 0; j < depth-1; j++ {
+		f := h.path.at(j)
+		if f.node != n || atomic.LoadInt32(&n.ref) != 1 ||
+			n.IsLeaf() || f.pos > n.count {
+			return replacedK, replacedV, false, false
+		}
+		if f.pos > 0 {
+			lo = &n.keys()[f.pos-1]
+		}
+		if f.pos < n.count {
+			hi = &n.keys()[f.pos]
+		}
+		n = n.children[f.pos]
+	}
+	leaf := h.path.at(depth - 1).node
+	if leaf != n || n == nil || atomic.LoadInt32(&n.ref) != 1 ||
+		!n.IsLeaf() || n.full() {
+		return replacedK, replacedV, false, false
+	}
+	if (lo != nil && t.cfg.cmp(*lo, item) >= 0) ||
+		(hi != nil && t.cfg.cmp(item, *hi) >= 0) {
+		return replacedK, replacedV, false, false
+	}
+	t.modified()
+	i, found := t.cfg.find(n, item)
+	if found {
+		replacedK, replacedV = n.keys()[i], n.value(i)
+		n.keys()[i] = item
+		n.setValue(i, value)
+		return replacedK, replacedV, true, true
+	}
+	n.insertAt(i, item, value, nil)
+	changed := n.updateOn(&t.cfg.Config, Insertion, item, nil)
+	for j := depth - 2; j >= 0 && changed; j-- {
+		changed = h.path.at(j).node.updateOn(&t.cfg.Config, Insertion, item, nil)
+	}
+	t.length++
+	return replacedK, replacedV, false, true
+}
@@ -71,3 +71,29 @@ func (is *iterStack[K, V, A]) reset() {
 		is.aLen = 0
 	}
 }
+
+func (is *iterStack[K, V, A]) at(i int) iterFrame[K, V, A] {
+	if is.aLen == -1 {
+		return is.s[i]
+	}
+	return is.a[i]
+}
+
+// clone returns a copy of the stack which does not alias any heap-allocated
+// storage of the receiver.
+func (is *iterStack[K, V, A]) clone() iterStack[K, V, A] {
+	c := *is
+	if is.aLen == -1 {
+		c.s = make([]iterFrame[K, V, A], len(is.s), cap(is.s))
+		copy(c.s, is.s)
+	}
+	return c
+}
+
+func (is *iterStack[K, V, A]) setNode(i int, n *Node[K, V, A]) {
+	if is.aLen == -1 {
+		is.s[i].node = n
+	} else {
+		is.a[i].node = n
+	}
+}
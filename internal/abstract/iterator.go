@@ -41,13 +41,17 @@ func (i *Iterator[K, V, A]) Reset() {
 // SeekGE seeks to the first key greater-than or equal to the provided
 // key.
 func (i *Iterator[K, V, A]) SeekGE(key K) {
+	if i.r.cfg.bplus {
+		i.seekGEBPlus(key)
+		return
+	}
 	i.Reset()
 	if i.node == nil {
 		return
 	}
 	ll := i.lowLevel()
 	for {
-		pos, found := i.node.find(i.r.cfg.cmp, key)
+		pos, found := i.r.cfg.find(i.node, key)
 		i.pos = int16(pos)
 		if found {
 			return
@@ -64,13 +68,17 @@ func (i *Iterator[K, V, A]) SeekGE(key K) {
 
 // SeekLT seeks to the last key less-than the provided key.
 func (i *Iterator[K, V, A]) SeekLT(key K) {
+	if i.r.cfg.bplus {
+		i.seekLTBPlus(key)
+		return
+	}
 	i.Reset()
 	if i.node == nil {
 		return
 	}
 	ll := i.lowLevel()
 	for {
-		pos, found := i.node.find(i.r.cfg.cmp, key)
+		pos, found := i.r.cfg.find(i.node, key)
 		i.pos = int16(pos)
 		if found || i.node.IsLeaf() {
 			i.Prev()
@@ -120,6 +128,10 @@ func (i *Iterator[K, V, A]) Next() {
 		if i.pos < i.node.count {
 			return
 		}
+		if i.r.cfg.bplus {
+			i.nextLeaf()
+			return
+		}
 		for i.s.len() > 0 && i.pos >= i.node.count {
 			ll.Ascend()
 		}
@@ -146,6 +158,10 @@ func (i *Iterator[K, V, A]) Prev() {
 		if i.pos >= 0 {
 			return
 		}
+		if i.r.cfg.bplus {
+			i.prevLeaf()
+			return
+		}
 		for i.s.len() > 0 && i.pos < 0 {
 			ll.Ascend()
 			i.pos--
@@ -169,11 +185,11 @@ func (i *Iterator[K, V, A]) Valid() bool {
 // Cur returns the key at the Iterator's current position. It is illegal
 // to call Key if the Iterator is not valid.
 func (i *Iterator[K, V, A]) Cur() K {
-	return i.node.keys[i.pos]
+	return i.node.keys()[i.pos]
 }
 
 // Value returns the value at the Iterator's current position. It is illegal
 // to call Value if the Iterator is not valid.
 func (i *Iterator[K, V, A]) Value() V {
-	return i.node.values[i.pos]
+	return i.node.value(int(i.pos))
 }
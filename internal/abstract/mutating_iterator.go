@@ -0,0 +1,70 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package abstract
+
+// MutatingIterator is an Iterator which can additionally modify the Map at
+// its current position. Mutations made through the iterator are subject to
+// the usual copy-on-write semantics, so clones and snapshots of the Map are
+// unaffected. Other iterators over the Map are invalidated by mutations.
+type MutatingIterator[K, V, A any] struct {
+	Iterator[K, V, A]
+}
+
+// MutatingIterator returns a new MutatingIterator for the Map.
+func (t *Map[K, V, A]) MutatingIterator() MutatingIterator[K, V, A] {
+	return MutatingIterator[K, V, A]{Iterator: t.Iterator()}
+}
+
+// SetValue replaces the value at the iterator's current position. It is
+// illegal to call SetValue if the iterator is not valid.
+func (i *MutatingIterator[K, V, A]) SetValue(v V) {
+	i.lowLevel().SetValue(v)
+}
+
+// DeleteCurrent removes the entry at the iterator's current position and
+// positions the iterator at the entry which followed it. It is illegal to
+// call DeleteCurrent if the iterator is not valid.
+func (i *MutatingIterator[K, V, A]) DeleteCurrent() {
+	i.lowLevel().DeleteCurrent()
+}
+
+// SetValue replaces the value at the iterator's current position, first
+// acquiring mutable references to each node on the path from the root.
+func (i *LowLevelIterator[K, V, A]) SetValue(v V) {
+	i.mutPath()
+	i.node.setValue(int(i.pos), v)
+}
+
+// DeleteCurrent removes the entry at the iterator's current position and
+// positions the iterator at the entry which followed it.
+func (i *LowLevelIterator[K, V, A]) DeleteCurrent() {
+	k := i.node.keys()[i.pos]
+	i.r.Delete(k)
+	(*Iterator[K, V, A])(i).SeekGE(k)
+}
+
+// mutPath acquires mutable references to each node on the path from the
+// root to the current node, updating the iterator's stack to refer to any
+// nodes which were cloned in the process.
+func (i *LowLevelIterator[K, V, A]) mutPath() {
+	i.r.modified()
+	np := i.r.cfg.np
+	n := mut(np, &i.r.root)
+	for j, depth := 0, i.s.len(); j < depth; j++ {
+		i.s.setNode(j, n)
+		n = mut(np, &n.children[i.s.at(j).pos])
+	}
+	i.node = n
+}
@@ -0,0 +1,85 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package abstract
+
+import (
+	"cmp"
+	"iter"
+)
+
+// Number is the set of numeric key types which support distance queries.
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// Nearest returns the entries of the Map whose keys are the k closest to x
+// according to dist, ordered by increasing distance. Of keys at equal
+// distances, the smaller comes first. The distance must not decrease as
+// keys move away from x in the order of the Map, which allows the search
+// to expand outwards from x with one iterator in each direction.
+func Nearest[K, V, A any, D cmp.Ordered](
+	t *Map[K, V, A], x K, k int, dist func(a, b K) D,
+) []Entry[K, V] {
+	if k <= 0 {
+		return nil
+	}
+	above := t.Iterator()
+	above.SeekGE(x)
+	below := above.Clone()
+	if below.Valid() {
+		below.Prev()
+	} else {
+		below.Last()
+	}
+	var entries []Entry[K, V]
+	for len(entries) < k && (below.Valid() || above.Valid()) {
+		it := &below
+		if !below.Valid() || (above.Valid() && dist(x, above.Cur()) < dist(x, below.Cur())) {
+			it = &above
+		}
+		entries = append(entries, Entry[K, V]{Key: it.Cur(), Value: it.Value()})
+		if it == &below {
+			below.Prev()
+		} else {
+			above.Next()
+		}
+	}
+	return entries
+}
+
+// WithinDistance returns a sequence of the entries of the Map with keys in
+// [x-d, x+d] in order. If x-d or x+d overflows, the range is unbounded in
+// that direction. The Map must order its keys numerically.
+func WithinDistance[K Number, V, A any](t *Map[K, V, A], x, d K) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		if !(d >= 0) {
+			return
+		}
+		lo, hi := x-d, x+d
+		it := t.Iterator()
+		if lo <= x {
+			it.SeekGE(lo)
+		} else {
+			it.First()
+		}
+		for ; it.Valid() && (hi < x || it.Cur() <= hi); it.Next() {
+			if !yield(it.Cur(), it.Value()) {
+				return
+			}
+		}
+	}
+}
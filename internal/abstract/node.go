@@ -19,20 +19,57 @@ import (
 	"fmt"
 	"strings"
 	"sync/atomic"
+	"unsafe"
 )
 
 // Node represents a node in the tree.
+//
+// The keys and values of a node are stored in arrays allocated alongside it
+// by one of the node types below, and are accessed through keys, values and
+// slots. The node does not refer to them with slices, which would make every
+// node larger and give the garbage collector more pointers to trace.
+//
+// All interior nodes and all non-root leaves have capacity MaxEntries. A leaf
+// at the root of a small tree may instead have capacity smallLeafEntries;
+// such a leaf is replaced by a full-sized one when it fills up.
+//
+// Values may be stored out of line: each is allocated separately and the
+// node holds a pointer to it in slots rather than holding it in values. This
+// makes cloning and restructuring nodes cheaper for large values. Values are
+// only stored out of line if requested with WithOutOfLineValues.
 type Node[K, V, A any] struct {
-	ref      int32
-	count    int16
-	aug      A
-	keys     [MaxEntries]K
-	values   [MaxEntries]V
-	children *[MaxEntries + 1]*Node[K, V, A]
+	ref       int32
+	count     int16
+	small     bool
+	outOfLine bool
+	aug       A
+	children  *[MaxEntries + 1]*Node[K, V, A]
+}
+
+// smallLeafEntries is the capacity of the leaf allocated at the root of a new
+// tree. It allows trees with few entries to occupy only a few cache lines.
+const smallLeafEntries = 8
+
+// The node types below allocate storage for the keys, values and children of
+// a Node alongside it. The type of the stored values, S, is either V or *V.
+// The keys of each type are at the same offset.
+
+type smallLeafNode[K, V, A, S any] struct {
+	Node[K, V, A]
+	keys   [smallLeafEntries]K
+	values [smallLeafEntries]S
 }
 
-type interiorNode[K, V, A any] struct {
+type leafNode[K, V, A, S any] struct {
 	Node[K, V, A]
+	keys   [MaxEntries]K
+	values [MaxEntries]S
+}
+
+type interiorNode[K, V, A, S any] struct {
+	Node[K, V, A]
+	keys     [MaxEntries]K
+	values   [MaxEntries]S
 	children [MaxEntries + 1]*Node[K, V, A]
 }
 
@@ -44,12 +81,103 @@ func (n *Node[K, V, A]) IsLeaf() bool {
 	return n.children == nil
 }
 
+// isSmall returns true if n is a leaf with less than MaxEntries capacity.
+func (n *Node[K, V, A]) isSmall() bool {
+	return n.small
+}
+
+// capacity returns the number of entries n can hold.
+func (n *Node[K, V, A]) capacity() int {
+	if n.small {
+		return smallLeafEntries
+	}
+	return MaxEntries
+}
+
+// full returns true if no more entries may be added to n.
+func (n *Node[K, V, A]) full() bool {
+	return int(n.count) >= n.capacity()
+}
+
+// keys returns the keys stored alongside n. Its length is the capacity of n.
+func (n *Node[K, V, A]) keys() []K {
+	p := unsafe.Add(unsafe.Pointer(n), unsafe.Offsetof(leafNode[K, V, A, V]{}.keys))
+	return unsafe.Slice((*K)(p), n.capacity())
+}
+
+// values returns the values stored alongside n. It must only be called if
+// values are stored in the node.
+func (n *Node[K, V, A]) values() []V {
+	return nodeValues[K, V, A, V](n)
+}
+
+// slots returns the pointers to the values stored out of line. It must only
+// be called if values are stored out of line.
+func (n *Node[K, V, A]) slots() []*V {
+	return nodeValues[K, V, A, *V](n)
+}
+
+// nodeValues returns the values of type S stored alongside n.
+func nodeValues[K, V, A, S any](n *Node[K, V, A]) []S {
+	off := unsafe.Offsetof(leafNode[K, V, A, S]{}.values)
+	if n.small {
+		off = unsafe.Offsetof(smallLeafNode[K, V, A, S]{}.values)
+	}
+	return unsafe.Slice((*S)(unsafe.Add(unsafe.Pointer(n), off)), n.capacity())
+}
+
+// value returns the value at index i. An out-of-line slot which was never
+// written, such as that of a B+tree separator, holds the zero value.
+func (n *Node[K, V, A]) value(i int) (v V) {
+	if n.outOfLine {
+		if p := n.slots()[i]; p != nil {
+			v = *p
+		}
+		return v
+	}
+	return n.values()[i]
+}
+
+// setValue sets the value at index i. An out-of-line slot is never modified
+// once written because it may be shared with clones of the node.
+func (n *Node[K, V, A]) setValue(i int, v V) {
+	if n.outOfLine {
+		n.slots()[i] = &v
+		return
+	}
+	n.values()[i] = v
+}
+
+// copyValues copies the values of src in [from, to) to n starting at index i.
+func (n *Node[K, V, A]) copyValues(i int, src *Node[K, V, A], from, to int) {
+	if n.outOfLine {
+		copy(n.slots()[i:], src.slots()[from:to])
+		return
+	}
+	copy(n.values()[i:], src.values()[from:to])
+}
+
+// clearValues clears the values in [from, to).
+func (n *Node[K, V, A]) clearValues(from, to int) {
+	if n.outOfLine {
+		clear(n.slots()[from:to])
+		return
+	}
+	clear(n.values()[from:to])
+}
+
 func (n *Node[K, V, A]) Count() int16 {
 	return n.count
 }
 
 func (n *Node[K, V, A]) GetKey(i int16) K {
-	return n.keys[i]
+	return n.keys()[i]
+}
+
+// GetKeys returns the keys of the node. The returned slice must not be
+// modified.
+func (n *Node[K, V, A]) GetKeys() []K {
+	return n.keys()[:n.count]
 }
 
 func (n *Node[K, V, A]) GetChild(i int16) *A {
@@ -121,16 +249,20 @@ func (n *Node[K, V, A]) clone(
 	np *nodePool[K, V, A],
 ) *Node[K, V, A] {
 	var c *Node[K, V, A]
-	if n.IsLeaf() {
-		c = np.getLeafNode()
-	} else {
+	switch {
+	case !n.IsLeaf():
 		c = np.getInteriorNode()
+	case n.isSmall():
+		c = np.getSmallLeafNode()
+	default:
+		c = np.getLeafNode()
 	}
 	// NB: copy field-by-field without touching n.ref to avoid
 	// triggering the race detector and looking like a data race.
 	c.count = n.count
 	c.aug = n.aug
-	c.keys = n.keys
+	copy(c.keys(), n.keys()[:n.count])
+	c.copyValues(0, n, 0, int(n.count))
 	if !c.IsLeaf() {
 		// Copy children and increase each refcount.
 		*c.children = *n.children
@@ -141,16 +273,28 @@ func (n *Node[K, V, A]) clone(
 	return c
 }
 
+// grow returns a full-sized leaf containing the entries of the small leaf n,
+// releasing the reference to n.
+func (n *Node[K, V, A]) grow(np *nodePool[K, V, A]) *Node[K, V, A] {
+	c := np.getLeafNode()
+	c.count = n.count
+	c.aug = n.aug
+	copy(c.keys(), n.keys()[:n.count])
+	c.copyValues(0, n, 0, int(n.count))
+	n.decRef(np, false /* recursive */)
+	return c
+}
+
 func (n *Node[K, V, A]) insertAt(index int, item K, value V, nd *Node[K, V, A]) {
 	if index < int(n.count) {
-		copy(n.keys[index+1:n.count+1], n.keys[index:n.count])
-		copy(n.values[index+1:n.count+1], n.values[index:n.count])
+		copy(n.keys()[index+1:n.count+1], n.keys()[index:n.count])
+		n.copyValues(index+1, n, index, int(n.count))
 		if !n.IsLeaf() {
 			copy(n.children[index+2:n.count+2], n.children[index+1:n.count+1])
 		}
 	}
-	n.keys[index] = item
-	n.values[index] = value
+	n.keys()[index] = item
+	n.setValue(index, value)
 	if !n.IsLeaf() {
 		n.children[index+1] = nd
 	}
@@ -158,8 +302,8 @@ func (n *Node[K, V, A]) insertAt(index int, item K, value V, nd *Node[K, V, A])
 }
 
 func (n *Node[K, V, A]) pushBack(item K, value V, nd *Node[K, V, A]) {
-	n.keys[n.count] = item
-	n.values[n.count] = value
+	n.keys()[n.count] = item
+	n.setValue(int(n.count), value)
 	if !n.IsLeaf() {
 		n.children[n.count+1] = nd
 	}
@@ -171,10 +315,10 @@ func (n *Node[K, V, A]) pushFront(item K, value V, nd *Node[K, V, A]) {
 		copy(n.children[1:n.count+2], n.children[:n.count+1])
 		n.children[0] = nd
 	}
-	copy(n.keys[1:n.count+1], n.keys[:n.count])
-	copy(n.values[1:n.count+1], n.values[:n.count])
-	n.keys[0] = item
-	n.values[0] = value
+	copy(n.keys()[1:n.count+1], n.keys()[:n.count])
+	n.copyValues(1, n, 0, int(n.count))
+	n.keys()[0] = item
+	n.setValue(0, value)
 	n.count++
 }
 
@@ -188,26 +332,24 @@ func (n *Node[K, V, A]) removeAt(index int) (K, V, *Node[K, V, A]) {
 		n.children[n.count] = nil
 	}
 	n.count--
-	outK := n.keys[index]
-	outV := n.values[index]
-	copy(n.keys[index:n.count], n.keys[index+1:n.count+1])
-	copy(n.values[index:n.count], n.values[index+1:n.count+1])
+	outK := n.keys()[index]
+	outV := n.value(index)
+	copy(n.keys()[index:n.count], n.keys()[index+1:n.count+1])
+	n.copyValues(index, n, index+1, int(n.count+1))
 	var rk K
-	var rv V
-	n.keys[n.count] = rk
-	n.values[n.count] = rv
+	n.keys()[n.count] = rk
+	n.clearValues(int(n.count), int(n.count+1))
 	return outK, outV, child
 }
 
 // popBack removes and returns the last element in the list.
 func (n *Node[K, V, A]) popBack() (K, V, *Node[K, V, A]) {
 	n.count--
-	outK := n.keys[n.count]
-	outV := n.values[n.count]
+	outK := n.keys()[n.count]
+	outV := n.value(int(n.count))
 	var rK K
-	var rV V
-	n.keys[n.count] = rK
-	n.values[n.count] = rV
+	n.keys()[n.count] = rK
+	n.clearValues(int(n.count), int(n.count+1))
 	if n.IsLeaf() {
 		return outK, outV, nil
 	}
@@ -225,14 +367,13 @@ func (n *Node[K, V, A]) popFront() (K, V, *Node[K, V, A]) {
 		copy(n.children[:n.count+1], n.children[1:n.count+2])
 		n.children[n.count+1] = nil
 	}
-	outK := n.keys[0]
-	outV := n.values[0]
-	copy(n.keys[:n.count], n.keys[1:n.count+1])
-	copy(n.values[:n.count], n.values[1:n.count+1])
+	outK := n.keys()[0]
+	outV := n.value(0)
+	copy(n.keys()[:n.count], n.keys()[1:n.count+1])
+	n.copyValues(0, n, 1, int(n.count+1))
 	var rK K
-	var rV V
-	n.keys[n.count] = rK
-	n.values[n.count] = rV
+	n.keys()[n.count] = rK
+	n.clearValues(int(n.count), int(n.count+1))
 	return outK, outV, child
 }
 
@@ -241,12 +382,15 @@ func (n *Node[K, V, A]) popFront() (K, V, *Node[K, V, A]) {
 // index.
 func (n *Node[K, V, A]) find(cmp func(K, K) int, item K) (index int, found bool) {
 	// Logic copied from sort.Search. Inlining this gave
-	// an 11% speedup on BenchmarkBTreeDeleteInsert.
-	i, j := 0, int(n.count)
+	// an 11% speedup on BenchmarkBTreeDeleteInsert. The keys are loaded
+	// once into a local slice so that the slice header is not reloaded
+	// from the node after each call to cmp.
+	keys := n.keys()[:n.count]
+	i, j := 0, len(keys)
 	for i < j {
 		h := int(uint(i+j) >> 1) // avoid overflow when computing h
 		// i ≤ h < j
-		c := cmp(item, n.keys[h])
+		c := cmp(item, keys[h])
 		if c < 0 {
 			j = h
 		} else if c > 0 {
@@ -279,8 +423,11 @@ func (n *Node[K, V, A]) find(cmp func(K, K) int, item K) (index int, found bool)
 //	|         x |     | z         |
 //	+-----------+     +-----------+
 func (n *Node[K, V, A]) split(cfg *config[K, V, A], i int) (K, V, *Node[K, V, A]) {
-	outK := n.keys[i]
-	outV := n.values[i]
+	if cfg.bplus && n.IsLeaf() {
+		return n.splitLeaf(cfg, i)
+	}
+	outK := n.keys()[i]
+	outV := n.value(i)
 	var next *Node[K, V, A]
 	if n.IsLeaf() {
 		next = cfg.np.getLeafNode()
@@ -288,14 +435,10 @@ func (n *Node[K, V, A]) split(cfg *config[K, V, A], i int) (K, V, *Node[K, V, A]
 		next = cfg.np.getInteriorNode()
 	}
 	next.count = n.count - int16(i+1)
-	copy(next.keys[:], n.keys[i+1:n.count])
-	copy(next.values[:], n.values[i+1:n.count])
-	var rK K
-	var rV V
-	for j := int16(i); j < n.count; j++ {
-		n.keys[j] = rK
-		n.values[j] = rV
-	}
+	copy(next.keys(), n.keys()[i+1:n.count])
+	next.copyValues(0, n, i+1, int(n.count))
+	clear(n.keys()[i:n.count])
+	n.clearValues(i, int(n.count))
 	if !n.IsLeaf() {
 		copy(next.children[:], n.children[i+1:n.count+1])
 		for j := int16(i + 1); j <= n.count; j++ {
@@ -313,6 +456,9 @@ func (n *Node[K, V, A]) update(cfg *Config[K, V, A]) bool {
 }
 
 func (n *Node[K, V, A]) updateWithMeta(cfg *Config[K, V, A], md UpdateInfo[K, A]) bool {
+	if cfg.compress != nil {
+		cfg.compress(n.keys()[:n.count])
+	}
 	if cfg.Updater == nil {
 		return false
 	}
@@ -320,7 +466,7 @@ func (n *Node[K, V, A]) updateWithMeta(cfg *Config[K, V, A], md UpdateInfo[K, A]
 }
 
 func (n *Node[K, V, A]) updateOn(cfg *Config[K, V, A], action Action, k K, affected *Node[K, V, A]) bool {
-	if cfg.Updater == nil {
+	if cfg.Updater == nil && cfg.compress == nil {
 		return false
 	}
 	var a *A
@@ -337,14 +483,24 @@ func (n *Node[K, V, A]) updateOn(cfg *Config[K, V, A], action Action, k K, affec
 // insert inserts an item into the suAugBTree rooted at this node, making sure no
 // nodes in the suAugBTree exceed MaxEntries keys. Returns true if an existing item
 // was replaced and false if an item was inserted. Also returns whether the
-// node's upper bound changes.
-func (n *Node[K, V, A]) insert(cfg *config[K, V, A], item K, value V) (replacedK K, replacedV V, replaced, newBound bool) {
-	i, found := n.find(cfg.cmp, item)
+// node's upper bound changes. If path is non-nil, each node visited is pushed
+// onto it along with the position of the child into which the insertion
+// descended.
+func (n *Node[K, V, A]) insert(
+	cfg *config[K, V, A], item K, value V, path *iterStack[K, V, A],
+) (replacedK K, replacedV V, replaced, newBound bool) {
+	if cfg.bplus {
+		return n.insertBPlus(cfg, item, value, path)
+	}
+	i, found := cfg.find(n, item)
+	if path != nil && n.IsLeaf() {
+		path.push(iterFrame[K, V, A]{node: n, pos: int16(i)})
+	}
 	if found {
-		replacedV = n.values[i]
-		replacedK = n.keys[i]
-		n.keys[i] = item
-		n.values[i] = value
+		replacedV = n.value(i)
+		replacedK = n.keys()[i]
+		n.keys()[i] = item
+		n.setValue(i, value)
 		return replacedK, replacedV, true, false
 	}
 	if n.IsLeaf() {
@@ -355,22 +511,25 @@ func (n *Node[K, V, A]) insert(cfg *config[K, V, A], item K, value V) (replacedK
 		splitLK, splitLV, splitNode := mut(cfg.np, &n.children[i]).
 			split(cfg, MaxEntries/2)
 		n.insertAt(i, splitLK, splitLV, splitNode)
-		if c := cfg.cmp(item, n.keys[i]); c < 0 {
+		if c := cfg.cmp(item, n.keys()[i]); c < 0 {
 			// no change, we want first split node
 		} else if c > 0 {
 			i++ // we want second split node
 		} else {
 			// TODO(ajwerner): add something to the augmentation api to
 			// deal with replacement.
-			replacedV = n.values[i]
-			replacedK = n.keys[i]
-			n.keys[i] = item
-			n.values[i] = value
+			replacedV = n.value(i)
+			replacedK = n.keys()[i]
+			n.keys()[i] = item
+			n.setValue(i, value)
 			return replacedK, replacedV, true, false
 		}
 	}
+	if path != nil {
+		path.push(iterFrame[K, V, A]{node: n, pos: int16(i)})
+	}
 	replacedK, replacedV, replaced, newBound =
-		mut(cfg.np, &n.children[i]).insert(cfg, item, value)
+		mut(cfg.np, &n.children[i]).insert(cfg, item, value, path)
 	if newBound {
 		newBound = n.updateOn(&cfg.Config, Insertion, item, nil)
 	}
@@ -381,13 +540,7 @@ func (n *Node[K, V, A]) insert(cfg *config[K, V, A], item K, value V) (replacedK
 // this node.
 func (n *Node[K, V, A]) removeMax(cfg *config[K, V, A]) (K, V) {
 	if n.IsLeaf() {
-		n.count--
-		outK := n.keys[n.count]
-		outV := n.values[n.count]
-		var rK K
-		var rV V
-		n.keys[n.count] = rK
-		n.values[n.count] = rV
+		outK, outV, _ := n.popBack()
 		n.updateOn(&cfg.Config, Removal, outK, nil)
 		return outK, outV
 	}
@@ -442,9 +595,10 @@ func (n *Node[K, V, A]) rebalanceOrMerge(
 		left := mut(cfg.np, &n.children[i-1])
 		child := mut(cfg.np, &n.children[i])
 		xLaK, xLaV, grandChild := left.popBack()
-		yLaK, yLaV := n.keys[i-1], n.values[i-1]
+		yLaK, yLaV := n.keys()[i-1], n.value(i-1)
 		child.pushFront(yLaK, yLaV, grandChild)
-		n.keys[i-1], n.values[i-1] = xLaK, xLaV
+		n.keys()[i-1] = xLaK
+		n.setValue(i-1, xLaV)
 		left.updateOn(&cfg.Config, Removal, xLaK, grandChild)
 		child.updateOn(&cfg.Config, Insertion, yLaK, grandChild)
 
@@ -480,9 +634,10 @@ func (n *Node[K, V, A]) rebalanceOrMerge(
 		right := mut(cfg.np, &n.children[i+1])
 		child := mut(cfg.np, &n.children[i])
 		xLaK, xLaV, grandChild := right.popFront()
-		yLaK, yLaV := n.keys[i], n.values[i]
+		yLaK, yLaV := n.keys()[i], n.value(i)
 		child.pushBack(yLaK, yLaV, grandChild)
-		n.keys[i], n.values[i] = xLaK, xLaV
+		n.keys()[i] = xLaK
+		n.setValue(i, xLaV)
 		right.updateOn(&cfg.Config, Removal, xLaK, grandChild)
 		child.updateOn(&cfg.Config, Insertion, yLaK, grandChild)
 
@@ -516,10 +671,10 @@ func (n *Node[K, V, A]) rebalanceOrMerge(
 		// Make mergeChild mutable, bumping the refcounts on its children if necessary.
 		_ = mut(cfg.np, &n.children[i+1])
 		mergeLaK, mergeLaV, mergeChild := n.removeAt(i)
-		child.keys[child.count] = mergeLaK
-		child.values[child.count] = mergeLaV
-		copy(child.keys[child.count+1:], mergeChild.keys[:mergeChild.count])
-		copy(child.values[child.count+1:], mergeChild.values[:mergeChild.count])
+		child.keys()[child.count] = mergeLaK
+		child.setValue(int(child.count), mergeLaV)
+		copy(child.keys()[child.count+1:], mergeChild.keys()[:mergeChild.count])
+		child.copyValues(int(child.count+1), mergeChild, 0, int(mergeChild.count))
 		if !child.IsLeaf() {
 			copy(child.children[child.count+1:], mergeChild.children[:mergeChild.count+1])
 		}
@@ -536,7 +691,10 @@ func (n *Node[K, V, A]) rebalanceOrMerge(
 func (n *Node[K, V, A]) remove(
 	cfg *config[K, V, A], item K,
 ) (outK K, outV V, found, newBound bool) {
-	i, found := n.find(cfg.cmp, item)
+	if cfg.bplus {
+		return n.removeBPlus(cfg, item)
+	}
+	i, found := cfg.find(n, item)
 	if n.IsLeaf() {
 		if found {
 			outK, outV, _ = n.removeAt(i)
@@ -554,9 +712,11 @@ func (n *Node[K, V, A]) remove(
 	child := mut(cfg.np, &n.children[i])
 	if found {
 		// Replace the item being removed with the max item in our left child.
-		outK = n.keys[i]
-		outV = n.values[i]
-		n.keys[i], n.values[i] = child.removeMax(cfg)
+		outK = n.keys()[i]
+		outV = n.value(i)
+		maxK, maxV := child.removeMax(cfg)
+		n.keys()[i] = maxK
+		n.setValue(i, maxV)
 		return outK, outV, true, n.updateOn(&cfg.Config, Removal, outK, nil)
 	}
 	// Latch is not in this node and child is large enough to remove from.
@@ -567,22 +727,26 @@ func (n *Node[K, V, A]) remove(
 	return outK, outV, found, newBound
 }
 
-func (n *Node[K, V, A]) writeString(b *strings.Builder) {
+// writeString writes a description of the subtree rooted at n. If
+// separators is true, the values of interior nodes are omitted.
+func (n *Node[K, V, A]) writeString(b *strings.Builder, separators bool) {
 	if n.IsLeaf() {
 		for i := int16(0); i < n.count; i++ {
 			if i != 0 {
 				b.WriteString(",")
 			}
-			fmt.Fprintf(b, "%v:%v", n.keys[i], n.values[i])
+			fmt.Fprintf(b, "%v:%v", n.keys()[i], n.value(int(i)))
 		}
 		return
 	}
 	for i := int16(0); i <= n.count; i++ {
 		b.WriteString("(")
-		n.children[i].writeString(b)
+		n.children[i].writeString(b, separators)
 		b.WriteString(")")
-		if i < n.count {
-			fmt.Fprintf(b, "%v:%v", n.keys[i], n.values[i])
+		if i < n.count && separators {
+			fmt.Fprintf(b, "%v", n.keys()[i])
+		} else if i < n.count {
+			fmt.Fprintf(b, "%v:%v", n.keys()[i], n.value(int(i)))
 		}
 	}
 }
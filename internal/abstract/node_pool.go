@@ -14,62 +14,213 @@
 
 package abstract
 
-import "sync"
+import (
+	"sync"
+	"unsafe"
+)
 
+// nodeKind distinguishes the node types allocated by a nodePool.
+type nodeKind int8
+
+const (
+	smallLeafKind nodeKind = iota
+	leafKind
+	interiorKind
+	numNodeKinds
+)
+
+// arenaSlabSize is the number of nodes allocated at once by an arena
+// Allocator.
+const arenaSlabSize = 16
+
+// nodePool allocates the nodes of one type on behalf of an Allocator.
 type nodePool[K, V, A any] struct {
-	interiorNodePool, leafNodePool sync.Pool
+	a *Allocator
+
+	// kind is the kind of the Allocator.
+	kind allocatorKind
+
+	// newNodes allocates n nodes of each kind in a single allocation.
+	newNodes [numNodeKinds]func(n int) []*Node[K, V, A]
+
+	// sizes is the size in bytes of the nodes of each kind.
+	sizes [numNodeKinds]int
+
+	// pools is used by pooled Allocators.
+	pools [numNodeKinds]sync.Pool
+
+	// slabs and freed are used by arena Allocators. Slabs holds nodes which
+	// have been allocated but never used while freed holds freed nodes.
+	mu    sync.Mutex
+	slabs [numNodeKinds][]*Node[K, V, A]
+	freed [numNodeKinds][]*Node[K, V, A]
 }
 
-var syncPoolMap sync.Map
+// nodePoolKey identifies the nodePool of an Allocator for a node type and
+// value storage.
+type nodePoolKey[K, V, A any] struct {
+	outOfLine bool
+}
 
-func getNodePool[K, V, A any]() *nodePool[K, V, A] {
-	var nilNode *Node[K, V, A]
-	v, ok := syncPoolMap.Load(nilNode)
+func getNodePool[K, V, A any](a *Allocator, outOfLine bool) *nodePool[K, V, A] {
+	key := nodePoolKey[K, V, A]{outOfLine: outOfLine}
+	v, ok := a.pools.Load(key)
 	if !ok {
-		v, _ = syncPoolMap.LoadOrStore(nilNode, newNodePool[K, V, A]())
+		v, _ = a.pools.LoadOrStore(key, newNodePool[K, V, A](a, outOfLine))
 	}
 	return v.(*nodePool[K, V, A])
+}
 
+func newNodePool[K, V, A any](a *Allocator, outOfLine bool) *nodePool[K, V, A] {
+	np := &nodePool[K, V, A]{a: a, kind: a.kind}
+	if outOfLine {
+		np.newNodes, np.sizes = makeNodeAllocators[K, V, A, *V](true)
+	} else {
+		np.newNodes, np.sizes = makeNodeAllocators[K, V, A, V](false)
+	}
+	return np
 }
 
-func newNodePool[K, V, A any]() *nodePool[K, V, A] {
-	np := nodePool[K, V, A]{}
-	np.leafNodePool = sync.Pool{
-		New: func() interface{} {
-			return new(Node[K, V, A])
-		},
+// makeNodeAllocators constructs functions which allocate nodes which store
+// values of type S along with the sizes of those nodes. If outOfLine is set,
+// S must be *V.
+func makeNodeAllocators[K, V, A, S any](
+	outOfLine bool,
+) (fns [numNodeKinds]func(int) []*Node[K, V, A], sizes [numNodeKinds]int) {
+	sizes[smallLeafKind] = int(unsafe.Sizeof(smallLeafNode[K, V, A, S]{}))
+	sizes[leafKind] = int(unsafe.Sizeof(leafNode[K, V, A, S]{}))
+	sizes[interiorKind] = int(unsafe.Sizeof(interiorNode[K, V, A, S]{}))
+	fns[smallLeafKind] = func(count int) []*Node[K, V, A] {
+		ns := make([]*Node[K, V, A], count)
+		slab := make([]smallLeafNode[K, V, A, S], count)
+		for i := range slab {
+			n := &slab[i]
+			n.small = true
+			n.outOfLine = outOfLine
+			ns[i] = &n.Node
+		}
+		return ns
+	}
+	fns[leafKind] = func(count int) []*Node[K, V, A] {
+		ns := make([]*Node[K, V, A], count)
+		slab := make([]leafNode[K, V, A, S], count)
+		for i := range slab {
+			n := &slab[i]
+			n.outOfLine = outOfLine
+			ns[i] = &n.Node
+		}
+		return ns
 	}
-	np.interiorNodePool = sync.Pool{
-		New: func() interface{} {
-			n := new(interiorNode[K, V, A])
+	fns[interiorKind] = func(count int) []*Node[K, V, A] {
+		ns := make([]*Node[K, V, A], count)
+		slab := make([]interiorNode[K, V, A, S], count)
+		for i := range slab {
+			n := &slab[i]
+			n.outOfLine = outOfLine
 			n.Node.children = &n.children
-			return &n.Node
-		},
+			ns[i] = &n.Node
+		}
+		return ns
 	}
-	return &np
+	return fns, sizes
 }
 
-func (np *nodePool[K, V, A]) getInteriorNode() *Node[K, V, A] {
-	n := np.interiorNodePool.Get().(*Node[K, V, A])
+func (np *nodePool[K, V, A]) get(k nodeKind) *Node[K, V, A] {
+	var n *Node[K, V, A]
+	switch np.kind {
+	case pooledAllocator:
+		if n, _ = np.pools[k].Get().(*Node[K, V, A]); n != nil {
+			np.a.counters.reused()
+		}
+	case arenaAllocator:
+		n = np.getSlab(k)
+	}
+	if n == nil {
+		n = np.newNodes[k](1)[0]
+		np.a.counters.allocated()
+	}
 	n.ref = 1
 	return n
 }
 
-func (np *nodePool[K, V, A]) getLeafNode() *Node[K, V, A] {
-	n := np.leafNodePool.Get().(*Node[K, V, A])
-	n.ref = 1
+// getSlab returns a freed node if one exists and otherwise the next unused
+// node of the current slab, allocating a new slab if necessary.
+func (np *nodePool[K, V, A]) getSlab(k nodeKind) *Node[K, V, A] {
+	np.mu.Lock()
+	defer np.mu.Unlock()
+	if freed := np.freed[k]; len(freed) > 0 {
+		n := freed[len(freed)-1]
+		freed[len(freed)-1] = nil
+		np.freed[k] = freed[:len(freed)-1]
+		np.a.counters.reused()
+		return n
+	}
+	if len(np.slabs[k]) == 0 {
+		np.slabs[k] = np.newNodes[k](arenaSlabSize)
+	}
+	slab := np.slabs[k]
+	n := slab[0]
+	np.slabs[k] = slab[1:]
+	np.a.counters.allocated()
 	return n
 }
 
+func (np *nodePool[K, V, A]) put(k nodeKind, n *Node[K, V, A]) {
+	np.a.counters.freed(1)
+	switch np.kind {
+	case pooledAllocator:
+		n.reset()
+		np.pools[k].Put(n)
+	case arenaAllocator:
+		n.reset()
+		np.mu.Lock()
+		np.freed[k] = append(np.freed[k], n)
+		np.mu.Unlock()
+	}
+}
+
+// free drops all of the nodes held by an arena Allocator.
+func (np *nodePool[K, V, A]) free() {
+	np.mu.Lock()
+	defer np.mu.Unlock()
+	np.slabs = [numNodeKinds][]*Node[K, V, A]{}
+	np.freed = [numNodeKinds][]*Node[K, V, A]{}
+}
+
+func (np *nodePool[K, V, A]) getInteriorNode() *Node[K, V, A] {
+	return np.get(interiorKind)
+}
+
+func (np *nodePool[K, V, A]) getLeafNode() *Node[K, V, A] {
+	return np.get(leafKind)
+}
+
+func (np *nodePool[K, V, A]) getSmallLeafNode() *Node[K, V, A] {
+	return np.get(smallLeafKind)
+}
+
 func (np *nodePool[K, V, A]) putInteriorNode(n *Node[K, V, A]) {
-	children := n.children
-	*children = [MaxEntries + 1]*Node[K, V, A]{}
-	*n = Node[K, V, A]{}
-	n.children = children
-	np.interiorNodePool.Put(n)
+	np.put(interiorKind, n)
 }
 
 func (np *nodePool[K, V, A]) putLeafNode(n *Node[K, V, A]) {
-	*n = Node[K, V, A]{}
-	np.leafNodePool.Put(n)
+	if n.isSmall() {
+		np.put(smallLeafKind, n)
+	} else {
+		np.put(leafKind, n)
+	}
+}
+
+// reset clears the node, retaining its storage.
+func (n *Node[K, V, A]) reset() {
+	clear(n.keys())
+	n.clearValues(0, n.capacity())
+	if n.children != nil {
+		*n.children = [MaxEntries + 1]*Node[K, V, A]{}
+	}
+	*n = Node[K, V, A]{
+		small:     n.small,
+		outOfLine: n.outOfLine,
+		children:  n.children,
+	}
 }
@@ -0,0 +1,52 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package abstract
+
+import "cmp"
+
+// MakeOrderedMap constructs a new Map for keys with a natural order. Keys are
+// ordered as by cmp.Compare, but searches within a node compare keys directly
+// rather than calling a comparison function for each key.
+func MakeOrderedMap[K cmp.Ordered, V, A any](
+	up Updater[K, V, A], opts ...Option,
+) Map[K, V, A] {
+	m := MakeMap[K, V, A](cmp.Compare[K], up, opts...)
+	m.cfg.search = searchOrdered[K]
+	return m
+}
+
+// linearSearchThreshold is the number of keys below which searchOrdered
+// switches from a binary search to a linear scan. Scanning a few adjacent
+// keys is cheaper than the mispredicted branches of a binary search.
+const linearSearchThreshold = 8
+
+// searchOrdered returns the index where the given item should be inserted
+// into the sorted keys and whether the item exists at that index.
+func searchOrdered[K cmp.Ordered](keys []K, item K) (index int, found bool) {
+	i, j := 0, len(keys)
+	for j-i > linearSearchThreshold {
+		h := int(uint(i+j) >> 1) // avoid overflow when computing h
+		// i ≤ h < j
+		if cmp.Less(keys[h], item) {
+			i = h + 1
+		} else {
+			j = h
+		}
+	}
+	for i < j && cmp.Less(keys[i], item) {
+		i++
+	}
+	return i, i < len(keys) && !cmp.Less(item, keys[i])
+}
@@ -0,0 +1,121 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package abstract
+
+import "sync/atomic"
+
+// Position is a compact record of an Iterator's position. It can be restored
+// into any Iterator over the same, unmodified version of the Map from which
+// it was taken. The version is identified by a number rather than by the
+// nodes of the Map, so a Position does not keep any part of the Map alive.
+type Position[K, V, A any] struct {
+	version uint64
+
+	// path holds the position in each node from the root down to and
+	// including the current node. Paths deeper than the fixed array, which
+	// are not expected in practice, spill into more.
+	depth int16
+	path  [iterStackDepth + 1]int16
+	more  []int16
+}
+
+func (p *Position[K, V, A]) push(pos int16) {
+	if int(p.depth) < len(p.path) {
+		p.path[p.depth] = pos
+	} else {
+		p.more = append(p.more, pos)
+	}
+	p.depth++
+}
+
+func (p *Position[K, V, A]) at(i int) int16 {
+	if i < len(p.path) {
+		return p.path[i]
+	}
+	return p.more[i-len(p.path)]
+}
+
+// positionVersions is the source of the versions assigned to Maps when a
+// Position is taken.
+var positionVersions atomic.Uint64
+
+// modified invalidates any Position taken from the Map.
+func (t *Map[K, V, A]) modified() {
+	if atomic.LoadUint64(&t.version) != 0 {
+		atomic.StoreUint64(&t.version, 0)
+	}
+}
+
+// positionVersion returns the version of the Map, assigning a new one if the
+// Map has been modified since a Position was last taken.
+func (t *Map[K, V, A]) positionVersion() uint64 {
+	for {
+		if v := atomic.LoadUint64(&t.version); v != 0 {
+			return v
+		}
+		v := positionVersions.Add(1)
+		if atomic.CompareAndSwapUint64(&t.version, 0, v) {
+			return v
+		}
+	}
+}
+
+// Clone returns an independent Iterator positioned at the same point as the
+// receiver. Subsequent movement of either iterator does not affect the other.
+func (i *Iterator[K, V, A]) Clone() Iterator[K, V, A] {
+	c := *i
+	c.s = i.s.clone()
+	return c
+}
+
+// Position returns a record of the Iterator's current position.
+func (i *Iterator[K, V, A]) Position() Position[K, V, A] {
+	p := Position[K, V, A]{version: i.r.positionVersion()}
+	for j, n := 0, i.s.len(); j < n; j++ {
+		p.push(i.s.at(j).pos)
+	}
+	p.push(i.pos)
+	return p
+}
+
+// SetPosition moves the Iterator to the recorded position. It returns false
+// and leaves the iterator invalid if the Position was taken from a different
+// version of the Map, or if it does not describe a valid entry, as is the
+// case for a Position taken from an invalid iterator.
+func (i *Iterator[K, V, A]) SetPosition(p Position[K, V, A]) (ok bool) {
+	i.Reset()
+	if p.version == 0 || p.version != atomic.LoadUint64(&i.r.version) ||
+		i.node == nil || p.depth == 0 {
+		return false
+	}
+	ll := i.lowLevel()
+	last := int(p.depth) - 1
+	for j := 0; j < last; j++ {
+		pos := p.at(j)
+		if i.node.IsLeaf() || pos < 0 || pos > i.node.count {
+			i.Reset()
+			return false
+		}
+		i.pos = pos
+		ll.Descend()
+	}
+	pos := p.at(last)
+	if pos < 0 || pos >= i.node.count {
+		i.Reset()
+		return false
+	}
+	i.pos = pos
+	return true
+}
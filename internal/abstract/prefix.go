@@ -0,0 +1,115 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package abstract
+
+import "iter"
+
+// Bytes is the set of key types which support prefix scans.
+type Bytes interface {
+	~string | ~[]byte
+}
+
+// PrefixEnd returns the smallest key which is greater than every key
+// beginning with the prefix. If there is no such key, as when the prefix
+// is empty or consists only of 0xff bytes, ok is false.
+func PrefixEnd[K Bytes](prefix K) (end K, ok bool) {
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] != 0xff {
+			b := append([]byte(nil), prefix[:i+1]...)
+			b[i]++
+			return K(b), true
+		}
+	}
+	return end, false
+}
+
+// HasPrefix returns whether k begins with the prefix.
+func HasPrefix[K Bytes](k, prefix K) bool {
+	return len(k) >= len(prefix) && string(k[:len(prefix)]) == string(prefix)
+}
+
+// PrefixIterator is an iterator over the entries of a Map whose keys begin
+// with a prefix. The Map must order its keys bytewise.
+type PrefixIterator[K Bytes, V, A any] struct {
+	it     Iterator[K, V, A]
+	prefix K
+}
+
+// ScanPrefix constructs a new PrefixIterator over the entries of the Map
+// whose keys begin with the prefix.
+func ScanPrefix[K Bytes, V, A any](t *Map[K, V, A], prefix K) PrefixIterator[K, V, A] {
+	return PrefixIterator[K, V, A]{it: t.Iterator(), prefix: prefix}
+}
+
+// Reset marks the iterator as invalid.
+func (i *PrefixIterator[K, V, A]) Reset() { i.it.Reset() }
+
+// First seeks to the first key with the prefix.
+func (i *PrefixIterator[K, V, A]) First() {
+	i.it.SeekGE(i.prefix)
+	i.check()
+}
+
+// Last seeks to the last key with the prefix.
+func (i *PrefixIterator[K, V, A]) Last() {
+	if end, ok := PrefixEnd(i.prefix); ok {
+		i.it.SeekLT(end)
+	} else {
+		i.it.Last()
+	}
+	i.check()
+}
+
+// Next positions the iterator to the following key with the prefix.
+func (i *PrefixIterator[K, V, A]) Next() {
+	i.it.Next()
+	i.check()
+}
+
+// Prev positions the iterator to the previous key with the prefix.
+func (i *PrefixIterator[K, V, A]) Prev() {
+	i.it.Prev()
+	i.check()
+}
+
+// Valid returns whether the iterator is positioned at a key with the
+// prefix.
+func (i *PrefixIterator[K, V, A]) Valid() bool { return i.it.Valid() }
+
+// Cur returns the key at the iterator's current position. It is illegal to
+// call Cur if the iterator is not valid.
+func (i *PrefixIterator[K, V, A]) Cur() K { return i.it.Cur() }
+
+// Value returns the value at the iterator's current position. It is illegal
+// to call Value if the iterator is not valid.
+func (i *PrefixIterator[K, V, A]) Value() V { return i.it.Value() }
+
+// All returns a sequence of the entries with the prefix in order.
+func (i *PrefixIterator[K, V, A]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for i.First(); i.Valid(); i.Next() {
+			if !yield(i.Cur(), i.Value()) {
+				return
+			}
+		}
+	}
+}
+
+// check invalidates the iterator if it has left the keys with the prefix.
+func (i *PrefixIterator[K, V, A]) check() {
+	if i.it.Valid() && !HasPrefix(i.it.Cur(), i.prefix) {
+		i.it.Reset()
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package abstract
+
+import (
+	"runtime"
+	"sync/atomic"
+)
+
+// Snapshot is a pinned, immutable version of a Map. It holds a reference to
+// the root of the Map at the time it was taken, so subsequent mutations to
+// the Map will copy-on-write rather than modify the nodes visible through the
+// Snapshot. A Snapshot must be closed to release its reference.
+type Snapshot[K, V, A any] struct {
+	m      Map[K, V, A]
+	closed bool
+}
+
+// onSnapshotLeak, if set, is invoked from a finalizer when a Snapshot is
+// garbage collected without having been closed.
+var onSnapshotLeak atomic.Pointer[func()]
+
+// SetSnapshotLeakHandler sets the function invoked when a Snapshot is garbage
+// collected without having been closed. The handler exists to surface the
+// missing call to Close, for example in tests. A nil function, the default,
+// disables the handler.
+//
+// A leaked Snapshot's reference is never released: the finalizer runs
+// concurrently with the owner of the Map, so it does not touch the shared
+// nodes or the Map's allocator. Those nodes are instead left to the garbage
+// collector, and the Map copies them when they are next modified.
+func SetSnapshotLeakHandler(f func()) {
+	if f == nil {
+		onSnapshotLeak.Store(nil)
+		return
+	}
+	onSnapshotLeak.Store(&f)
+}
+
+// Snapshot pins the current version of the Map. The caller must call Close
+// on the returned Snapshot when done with it.
+func (t *Map[K, V, A]) Snapshot() *Snapshot[K, V, A] {
+	s := &Snapshot[K, V, A]{m: t.Clone()}
+	runtime.SetFinalizer(s, (*Snapshot[K, V, A]).finalize)
+	return s
+}
+
+// Iterator returns a new Iterator over the pinned version of the Map. The
+// Iterator may not be used after the Snapshot is closed.
+func (s *Snapshot[K, V, A]) Iterator() Iterator[K, V, A] {
+	return s.m.Iterator()
+}
+
+// Close releases the Snapshot's reference to the pinned version of the Map.
+// It is safe to call Close more than once.
+func (s *Snapshot[K, V, A]) Close() {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	s.m.Reset()
+	runtime.SetFinalizer(s, nil)
+}
+
+func (s *Snapshot[K, V, A]) finalize() {
+	if s.closed {
+		return
+	}
+	if f := onSnapshotLeak.Load(); f != nil {
+		(*f)()
+	}
+}
+
+// SnapshotIterator is an Iterator over a pinned version of a Map. It remains
+// valid while the Map it was created from is mutated. It must be closed when
+// no longer in use.
+type SnapshotIterator[K, V, A any] struct {
+	Iterator[K, V, A]
+	s *Snapshot[K, V, A]
+}
+
+// SnapshotIterator returns an iterator over the current version of the Map
+// which is unaffected by subsequent mutations. The caller must call Close
+// on the returned iterator to release the pinned version.
+func (t *Map[K, V, A]) SnapshotIterator() SnapshotIterator[K, V, A] {
+	s := t.Snapshot()
+	return SnapshotIterator[K, V, A]{Iterator: s.Iterator(), s: s}
+}
+
+// Close releases the version of the Map pinned by the iterator. The
+// iterator is invalid after Close returns.
+func (i *SnapshotIterator[K, V, A]) Close() {
+	i.s.Close()
+	i.Reset()
+}
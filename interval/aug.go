@@ -15,7 +15,12 @@
 
 package interval
 
-import "github.com/ajwerner/btree/internal/abstract"
+import (
+	"cmp"
+	"sort"
+
+	"github.com/ajwerner/btree/internal/abstract"
+)
 
 type aug[K any] struct {
 	keyBound[K]
@@ -25,6 +30,11 @@ type updater[I, K, V any] struct {
 	key, end func(I) K
 	cmp      Cmp[K]
 	hasEnd   func(I) bool
+
+	// search and reaches, if non-nil, are used in place of cmp to implement
+	// searchBound and reachesKey, respectively. See orderedBounds.
+	search  func(intervals []I, b keyBound[K]) int
+	reaches func(interval I, k K) bool
 }
 
 func (u *updater[I, K, V]) Update(
@@ -63,15 +73,22 @@ func (u *updater[I, K, V]) Update(
 			return false
 		}
 		fallthrough
-	case abstract.Default:
+	default:
+		// Default, and any action which is not known, recomputes the bound.
 		prev := a.keyBound
 		a.keyBound = u.findUpperBound(n)
 		return a.compare(u.cmp, prev) != 0
-	default:
-		panic("")
 	}
 }
 
+// validate returns an error if the interval is invalid.
+func (u *updater[I, K, V]) validate(interval I) error {
+	if u.hasEnd(interval) && u.cmp(u.key(interval), u.end(interval)) > 0 {
+		return ErrInvalidInterval
+	}
+	return nil
+}
+
 type keyBound[K any] struct {
 	k         K
 	inclusive bool
@@ -84,6 +101,81 @@ func (up *updater[I, K, V]) upperBound(interval I) keyBound[K] {
 	return keyBound[K]{k: up.end(interval)}
 }
 
+// searchBound returns the index of the first of the sorted intervals whose
+// key is not contained in the bound.
+func (up *updater[I, K, V]) searchBound(intervals []I, b keyBound[K]) int {
+	if up.search != nil {
+		return up.search(intervals, b)
+	}
+	return sort.Search(len(intervals), func(j int) bool {
+		return !b.contains(up.cmp, up.key(intervals[j]))
+	})
+}
+
+// reachesKey returns true if the upper bound of the interval contains k.
+func (up *updater[I, K, V]) reachesKey(interval I, k K) bool {
+	if up.reaches != nil {
+		return up.reaches(interval, k)
+	}
+	return up.upperBound(interval).contains(up.cmp, k)
+}
+
+// linearSearchThreshold is the number of intervals below which the search
+// returned by orderedBounds switches from a binary search to a linear scan.
+const linearSearchThreshold = 8
+
+// orderedBounds returns implementations of searchBound and reachesKey for
+// bounds with a natural order which compare the bounds directly rather than
+// through a comparison function. A nil hasEnd is treated as it is by
+// MakeMap.
+func orderedBounds[I any, K cmp.Ordered](
+	key, end func(I) K, hasEnd func(I) bool,
+) (
+	search func(intervals []I, b keyBound[K]) int,
+	reaches func(interval I, k K) bool,
+) {
+	search = func(intervals []I, b keyBound[K]) int {
+		// contained returns true if k is contained in b.
+		contained := func(k K) bool {
+			if b.inclusive {
+				return !cmp.Less(b.k, k)
+			}
+			return cmp.Less(k, b.k)
+		}
+		i, j := 0, len(intervals)
+		for j-i > linearSearchThreshold {
+			h := int(uint(i+j) >> 1) // avoid overflow when computing h
+			// i ≤ h < j
+			if contained(key(intervals[h])) {
+				i = h + 1
+			} else {
+				j = h
+			}
+		}
+		for i < j && contained(key(intervals[i])) {
+			i++
+		}
+		return i
+	}
+	if hasEnd == nil {
+		reaches = func(interval I, k K) bool {
+			var zero K
+			if e := end(interval); cmp.Compare(e, zero) != 0 {
+				return cmp.Less(k, e)
+			}
+			return !cmp.Less(key(interval), k)
+		}
+	} else {
+		reaches = func(interval I, k K) bool {
+			if hasEnd(interval) {
+				return cmp.Less(k, end(interval))
+			}
+			return !cmp.Less(key(interval), k)
+		}
+	}
+	return search, reaches
+}
+
 func isZero[K any](cmp Cmp[K], k K) bool {
 	var z K
 	return cmp(k, z) == 0
@@ -14,12 +14,20 @@
 
 package interval
 
-import "github.com/ajwerner/btree/internal/abstract"
+import (
+	"cmp"
+	"errors"
+
+	"github.com/ajwerner/btree/internal/abstract"
+)
 
 // Map is a ordered map from I to V where I is an interval. Its iterator
 // provides efficient overlap queries.
 type Map[I, K, V any] struct {
 	abstract.Map[I, V, aug[K]]
+
+	// up is the updater with which the Map was constructed.
+	up *updater[I, K, V]
 }
 
 type config[I, K any] struct {
@@ -34,30 +42,90 @@ func MakeMap[I, K, V any](
 	cmpI Cmp[I],
 	key, endKey func(I) K,
 	hasEnd func(I) bool,
+	opts ...Option,
+) Map[I, K, V] {
+	return makeMap[I, K, V](cmpK, cmpI, key, endKey, hasEnd, nil, nil, opts)
+}
+
+// MakeOrderedMap constructs a new map for intervals whose bounds have a
+// natural order. It orders bounds as MakeMap with cmp.Compare does, but
+// overlap scans compare bounds directly rather than calling a comparison
+// function for each interval.
+func MakeOrderedMap[I any, K cmp.Ordered, V any](
+	cmpI Cmp[I],
+	key, endKey func(I) K,
+	hasEnd func(I) bool,
+	opts ...Option,
+) Map[I, K, V] {
+	search, reaches := orderedBounds[I](key, endKey, hasEnd)
+	return makeMap[I, K, V](
+		cmp.Compare[K], cmpI, key, endKey, hasEnd, search, reaches, opts,
+	)
+}
+
+func makeMap[I, K, V any](
+	cmpK Cmp[K],
+	cmpI Cmp[I],
+	key, endKey func(I) K,
+	hasEnd func(I) bool,
+	search func([]I, keyBound[K]) int,
+	reaches func(I, K) bool,
+	opts []Option,
 ) Map[I, K, V] {
 	if hasEnd == nil {
 		hasEnd = func(i I) bool {
 			return !isZero(cmpK, endKey(i))
 		}
 	}
+	up := &updater[I, K, V]{
+		cmp:     cmpK,
+		key:     key,
+		end:     endKey,
+		hasEnd:  hasEnd,
+		search:  search,
+		reaches: reaches,
+	}
 	return Map[I, K, V]{
-		Map: abstract.MakeMap[I, V, aug[K]](
-			cmpI,
-			&updater[I, K, V]{
-				cmp:    cmpK,
-				key:    key,
-				end:    endKey,
-				hasEnd: hasEnd,
-			},
-		),
+		Map: abstract.MakeMap[I, V, aug[K]](cmpI, up, opts...),
+		up:  up,
 	}
 }
 
 // Clone clones the Map, lazily. It does so in constant time.
 func (m *Map[I, K, V]) Clone() Map[I, K, V] {
-	return Map[I, K, V]{Map: m.Map.Clone()}
+	return Map[I, K, V]{Map: m.Map.Clone(), up: m.up}
 }
 
+// ErrInvalidInterval is returned by TryUpsert for an interval whose start is
+// greater than its end.
+var ErrInvalidInterval = errors.New("interval: start greater than end")
+
+// TryUpsert is like Upsert but returns ErrInvalidInterval rather than
+// inserting an invalid interval. Upsert does not validate its input;
+// inserting an invalid interval leads to incorrect overlap queries.
+func (m *Map[I, K, V]) TryUpsert(
+	item I, value V,
+) (replaced I, replacedV V, overwrote bool, err error) {
+	if err := m.up.validate(item); err != nil {
+		return replaced, replacedV, false, err
+	}
+	replaced, replacedV, overwrote = m.Upsert(item, value)
+	return replaced, replacedV, overwrote, nil
+}
+
+// Entry is an interval-value pair in a Map.
+type Entry[I, V any] = abstract.Entry[I, V]
+
+// UpsertHint accelerates repeated insertions of nearby intervals into a Map
+// or Set. The zero value is ready to use.
+type UpsertHint[I, K, V any] = abstract.UpsertHint[I, V, aug[K]]
+
+// CompactionStats describes the effect of a call to Compact.
+type CompactionStats = abstract.CompactionStats
+
+// Option configures a Map or Set. See btree.WithAllocator.
+type Option = abstract.Option
+
 // Cmp is a comparison function for type T.
 type Cmp[T any] func(T, T) int
 
@@ -68,6 +136,17 @@ func (t *Map[I, K, V]) Iterator() Iterator[I, K, V] {
 	}
 }
 
+// SnapshotIterator constructs a new SnapshotIterator over the current
+// version of the Map. The iterator is unaffected by subsequent mutations
+// to the Map and must be closed when no longer in use.
+func (t *Map[I, K, V]) SnapshotIterator() SnapshotIterator[I, K, V] {
+	s := t.Map.Snapshot()
+	return SnapshotIterator[I, K, V]{
+		Iterator: Iterator[I, K, V]{Iterator: s.Iterator()},
+		s:        s,
+	}
+}
+
 // Set is an ordered set with items of type T which additionally offers the
 // methods of an order-statistic tree on its iterator.
 type Set[I, T any] Map[I, T, struct{}]
@@ -78,8 +157,20 @@ func MakeSet[I, T any](
 	cmpI Cmp[I],
 	key, endKey func(I) T,
 	hasEnd func(I) bool,
+	opts ...Option,
+) Set[I, T] {
+	return (Set[I, T])(MakeMap[I, T, struct{}](cmpT, cmpI, key, endKey, hasEnd, opts...))
+}
+
+// MakeOrderedSet constructs a new Set for intervals whose bounds have a
+// natural order. It is equivalent to MakeSet with cmp.Compare for the bounds.
+func MakeOrderedSet[I any, T cmp.Ordered](
+	cmpI Cmp[I],
+	key, endKey func(I) T,
+	hasEnd func(I) bool,
+	opts ...Option,
 ) Set[I, T] {
-	return (Set[I, T])(MakeMap[I, T, struct{}](cmpT, cmpI, key, endKey, hasEnd))
+	return (Set[I, T])(MakeOrderedMap[I, T, struct{}](cmpI, key, endKey, hasEnd, opts...))
 }
 
 // Clone clones the Set, lazily. It does so in constant time.
@@ -94,6 +185,29 @@ func (t *Set[I, T]) Upsert(item I) (replaced I, overwrote bool) {
 	return replaced, overwrote
 }
 
+// TryUpsert is like Upsert but returns ErrInvalidInterval rather than
+// inserting an invalid interval.
+func (t *Set[I, T]) TryUpsert(item I) (replaced I, overwrote bool, err error) {
+	replaced, _, overwrote, err = (*Map[I, T, struct{}])(t).TryUpsert(item, struct{}{})
+	return replaced, overwrote, err
+}
+
+// UpsertWithHint is like Upsert but uses and updates the provided hint to
+// accelerate insertions of items which are near each other.
+func (t *Set[I, T]) UpsertWithHint(item I, h *UpsertHint[I, T, struct{}]) (replaced I, overwrote bool) {
+	replaced, _, overwrote = t.Map.UpsertWithHint(item, struct{}{}, h)
+	return replaced, overwrote
+}
+
+// UpsertBatch upserts each of the provided items. It is most efficient when
+// the items are sorted.
+func (t *Set[I, T]) UpsertBatch(items []I) {
+	var h UpsertHint[I, T, struct{}]
+	for _, item := range items {
+		t.UpsertWithHint(item, &h)
+	}
+}
+
 // Delete removes the value with the provided key. It returns true if the
 // item existed in the set.
 func (t *Set[I, T]) Delete(item I) (removed bool) {
@@ -105,3 +219,9 @@ func (t *Set[I, T]) Delete(item I) (removed bool) {
 func (t *Set[I, T]) Iterator() Iterator[I, T, struct{}] {
 	return (*Map[I, T, struct{}])(t).Iterator()
 }
+
+// SnapshotIterator constructs a new SnapshotIterator over the current
+// version of this set.
+func (t *Set[I, T]) SnapshotIterator() SnapshotIterator[I, T, struct{}] {
+	return (*Map[I, T, struct{}])(t).SnapshotIterator()
+}
@@ -824,3 +824,30 @@ func BenchmarkBTreeIterOverlapScan(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkBTreeUpsertAppend(b *testing.B) {
+	const count = 1 << 14
+	latches := make([]*latch, count)
+	for i := range latches {
+		latches[i] = newLatch(spanWithEnd(i, i+1))
+	}
+	b.Run("upsert", func(b *testing.B) {
+		for i := 0; i < b.N; i += count {
+			tr := makeBTree()
+			for _, la := range latches {
+				tr.Upsert(la, struct{}{})
+			}
+			tr.Reset()
+		}
+	})
+	b.Run("hint", func(b *testing.B) {
+		for i := 0; i < b.N; i += count {
+			tr := makeBTree()
+			var h UpsertHint[*latch, Key, struct{}]
+			for _, la := range latches {
+				tr.UpsertWithHint(la, struct{}{}, &h)
+			}
+			tr.Reset()
+		}
+	})
+}
@@ -16,7 +16,10 @@ package interval
 
 import (
 	"cmp"
+	"errors"
+	"math/rand"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -24,8 +27,8 @@ import (
 // Interval represents an interval with bounds from [Key(), End()) where
 // Key() is inclusive and End() is exclusive. If Key() == End(), then the
 // Inteval represents a point that only includes that value. Intervals with
-// Key() which is larger than End() are invalid and may result in panics
-// upon insertion.
+// Key() which is larger than End() are invalid and are rejected by
+// TryUpsert.
 type Interval[K any] interface {
 	Key() K
 	End() K
@@ -107,3 +110,225 @@ func TestIntervalTree(t *testing.T) {
 		require.Equal(t, tc.res, res)
 	}
 }
+
+func TestIteratorCloneOverlap(t *testing.T) {
+	tree := MakeSet[IntInterval, int](
+		cmp.Compare[int],
+		IntervalCompare[IntInterval](cmp.Compare[int]),
+		IntInterval.Key,
+		IntInterval.End,
+		nil,
+	)
+	for i := 0; i < 1000; i++ {
+		tree.Upsert(IntInterval{i, i + 10})
+	}
+	collect := func(it *Iterator[IntInterval, int, struct{}]) (res []IntInterval) {
+		for ; it.Valid(); it.NextOverlap() {
+			res = append(res, it.Cur())
+		}
+		return res
+	}
+	it := tree.Iterator()
+	it.FirstOverlap(IntInterval{400, 600})
+	for i := 0; i < 50; i++ {
+		it.NextOverlap()
+	}
+	c := it.Clone()
+	fresh := tree.Iterator()
+	require.True(t, fresh.SetPosition(it.Position()))
+	exp := collect(&it)
+	require.Len(t, exp, 159)
+	require.Equal(t, exp, collect(&c))
+	require.Equal(t, exp, collect(&fresh))
+
+	// A Position taken at any point of the scan resumes it.
+	it.FirstOverlap(IntInterval{400, 600})
+	all := collect(&it)
+	it.FirstOverlap(IntInterval{400, 600})
+	for j := 0; it.Valid(); j++ {
+		fresh := tree.Iterator()
+		require.True(t, fresh.SetPosition(it.Position()))
+		require.Equal(t, all[j:], collect(&fresh))
+		it.NextOverlap()
+	}
+}
+
+func TestSnapshotIteratorOverlap(t *testing.T) {
+	tree := MakeSet[IntInterval, int](
+		cmp.Compare[int],
+		IntervalCompare[IntInterval](cmp.Compare[int]),
+		IntInterval.Key,
+		IntInterval.End,
+		nil,
+	)
+	for i := 0; i < 1000; i++ {
+		tree.Upsert(IntInterval{i, i + 10})
+	}
+	collect := func(it *Iterator[IntInterval, int, struct{}], q IntInterval) (res []IntInterval) {
+		for it.FirstOverlap(q); it.Valid(); it.NextOverlap() {
+			res = append(res, it.Cur())
+		}
+		return res
+	}
+	q := IntInterval{400, 600}
+	live := tree.Iterator()
+	exp := collect(&live, q)
+
+	// Mutations of the set, including ones which change the augmentation of
+	// the nodes visited by the overlap scan, are not visible to the snapshot.
+	it := tree.SnapshotIterator()
+	for i := 0; i < 1000; i += 2 {
+		tree.Delete(IntInterval{i, i + 10})
+	}
+	tree.Upsert(IntInterval{0, 2000})
+	require.Equal(t, exp, collect(&it.Iterator, q))
+	require.NotEqual(t, exp, collect(&live, q))
+
+	it.Close()
+	it.First()
+	require.False(t, it.Valid())
+}
+
+func TestUpsertBatchOverlap(t *testing.T) {
+	tree := MakeSet[IntInterval, int](
+		cmp.Compare[int],
+		IntervalCompare[IntInterval](cmp.Compare[int]),
+		IntInterval.Key,
+		IntInterval.End,
+		nil,
+	)
+	items := make([]IntInterval, 0, 2000)
+	for i := 0; i < 2000; i++ {
+		end := i + 1
+		if i%500 == 0 {
+			end = i + 1000
+		}
+		items = append(items, IntInterval{i, end})
+	}
+	tree.UpsertBatch(items)
+	it := tree.Iterator()
+	var res []IntInterval
+	for it.FirstOverlap(IntInterval{1200, 1201}); it.Valid(); it.NextOverlap() {
+		res = append(res, it.Cur())
+	}
+	require.Equal(t, []IntInterval{{500, 1500}, {1000, 2000}, {1200, 1201}}, res)
+}
+
+func TestOrderedSetOverlap(t *testing.T) {
+	tree := MakeOrderedSet[IntInterval, int](
+		IntervalCompare[IntInterval](cmp.Compare[int]),
+		IntInterval.Key,
+		IntInterval.End,
+		nil,
+	)
+	for i := 0; i < 1000; i++ {
+		tree.Upsert(IntInterval{i, i + 3})
+	}
+	it := tree.Iterator()
+	var res []IntInterval
+	for it.FirstOverlap(IntInterval{500, 501}); it.Valid(); it.NextOverlap() {
+		res = append(res, it.Cur())
+	}
+	require.Equal(t, []IntInterval{{498, 501}, {499, 502}, {500, 503}}, res)
+}
+
+func TestOrderedSetMatchesFunc(t *testing.T) {
+	rng := newRand(t)
+	ordered := MakeOrderedSet[IntInterval, int](
+		IntervalCompare[IntInterval](cmp.Compare[int]),
+		IntInterval.Key,
+		IntInterval.End,
+		nil,
+	)
+	ref := MakeSet[IntInterval, int](
+		cmp.Compare[int],
+		IntervalCompare[IntInterval](cmp.Compare[int]),
+		IntInterval.Key,
+		IntInterval.End,
+		nil,
+	)
+	for i := 0; i < 5000; i++ {
+		start := rng.Intn(10000)
+		item := IntInterval{start, start + rng.Intn(100)}
+		ordered.Upsert(item)
+		ref.Upsert(item)
+	}
+	collect := func(s *Set[IntInterval, int], q IntInterval) (res []IntInterval) {
+		it := s.Iterator()
+		for it.FirstOverlap(q); it.Valid(); it.NextOverlap() {
+			res = append(res, it.Cur())
+		}
+		return res
+	}
+	for i := 0; i < 1000; i++ {
+		start := rng.Intn(10000)
+		q := IntInterval{start, start + rng.Intn(50)}
+		require.Equal(t, collect(&ref, q), collect(&ordered, q))
+	}
+}
+
+func BenchmarkOrderedMap(b *testing.B) {
+	const count = 1 << 16
+	for _, tc := range []struct {
+		name string
+		make func() Set[IntInterval, int]
+	}{
+		{"func", func() Set[IntInterval, int] {
+			return MakeSet[IntInterval, int](
+				cmp.Compare[int], IntervalCompare[IntInterval](cmp.Compare[int]),
+				IntInterval.Key, IntInterval.End, nil,
+			)
+		}},
+		{"ordered", func() Set[IntInterval, int] {
+			return MakeOrderedSet[IntInterval, int](
+				IntervalCompare[IntInterval](cmp.Compare[int]),
+				IntInterval.Key, IntInterval.End, nil,
+			)
+		}},
+	} {
+		s := tc.make()
+		for i := 0; i < count; i++ {
+			s.Upsert(IntInterval{i, i + 10})
+		}
+		b.Run(tc.name+"/first-overlap", func(b *testing.B) {
+			it := s.Iterator()
+			for i := 0; i < b.N; i++ {
+				k := i % count
+				it.FirstOverlap(IntInterval{k, k + 1})
+			}
+		})
+		b.Run(tc.name+"/overlap-scan", func(b *testing.B) {
+			it := s.Iterator()
+			for i := 0; i < b.N; i++ {
+				k := i % count
+				for it.FirstOverlap(IntInterval{k, k + 100}); it.Valid(); it.NextOverlap() {
+				}
+			}
+		})
+	}
+}
+
+func TestTryUpsert(t *testing.T) {
+	tree := MakeOrderedSet[IntInterval, int](
+		IntervalCompare[IntInterval](cmp.Compare[int]),
+		IntInterval.Key,
+		IntInterval.End,
+		nil,
+	)
+	for _, item := range []IntInterval{{1, 4}, {3, 3}, {5, 0}} {
+		_, _, err := tree.TryUpsert(item)
+		require.NoError(t, err)
+	}
+	_, _, err := tree.TryUpsert(IntInterval{4, 2})
+	require.True(t, errors.Is(err, ErrInvalidInterval))
+	require.Equal(t, 3, tree.Len())
+}
+
+// newRand returns a source of randomness for a test, logging its seed so
+// that a failure can be reproduced.
+func newRand(t testing.TB) *rand.Rand {
+	t.Helper()
+	seed := time.Now().UnixNano()
+	t.Logf("seed: %d", seed)
+	return rand.New(rand.NewSource(seed))
+}
@@ -15,11 +15,7 @@
 
 package interval
 
-import (
-	"sort"
-
-	"github.com/ajwerner/btree/internal/abstract"
-)
+import "github.com/ajwerner/btree/internal/abstract"
 
 type Iterator[I, K, V any] struct {
 	abstract.Iterator[I, V, aug[K]]
@@ -27,6 +23,20 @@ type Iterator[I, K, V any] struct {
 	o overlapScan[I, K, V]
 }
 
+// SnapshotIterator is an Iterator over a pinned version of a Map. It must be
+// closed when no longer in use.
+type SnapshotIterator[I, K, V any] struct {
+	Iterator[I, K, V]
+	s *abstract.Snapshot[I, V, aug[K]]
+}
+
+// Close releases the version of the Map pinned by the iterator. The
+// iterator is invalid after Close returns.
+func (i *SnapshotIterator[I, K, V]) Close() {
+	i.s.Close()
+	i.Reset()
+}
+
 // An overlap scan is a scan over all latches that overlap with the provided
 // latch in order of the overlapping latches' start keys. The goal of the scan
 // is to minimize the number of key comparisons performed in total. The
@@ -119,6 +129,77 @@ func (i *Iterator[I, K, V]) Reset() {
 	i.Iterator.Reset()
 }
 
+// Clone returns an independent Iterator at the same position, including
+// the state of any overlap scan in progress.
+func (i *Iterator[I, K, V]) Clone() Iterator[I, K, V] {
+	return Iterator[I, K, V]{Iterator: i.Iterator.Clone(), o: i.o}
+}
+
+// Position records the position of an Iterator, including the state of any
+// overlap scan in progress, so that it can later be restored into an Iterator
+// over the same version of the Map. Like the Positions of other maps, it does
+// not keep any part of the Map alive: the scan's constraints, which refer to
+// nodes, are recomputed when the Position is restored.
+type Position[I, K, V any] struct {
+	p abstract.Position[I, V, aug[K]]
+
+	// The overlap scan in progress, if set.
+	bounds     I
+	set        bool
+	minReached bool
+}
+
+// Position returns a record of the Iterator's current position.
+func (i *Iterator[I, K, V]) Position() Position[I, K, V] {
+	return Position[I, K, V]{
+		p:          i.Iterator.Position(),
+		bounds:     i.o.bounds,
+		set:        i.o.set,
+		minReached: i.o.constrMinReached,
+	}
+}
+
+// SetPosition moves the Iterator to the recorded position. It returns false
+// and leaves the iterator invalid if the Position was taken from a different
+// version of the Map.
+func (i *Iterator[I, K, V]) SetPosition(p Position[I, K, V]) (ok bool) {
+	i.o.reset()
+	if !i.Iterator.SetPosition(p.p) {
+		return false
+	}
+	if p.set {
+		i.o = overlapScan[I, K, V]{bounds: p.bounds, set: true, constrMinReached: p.minReached}
+		i.restoreSearchBounds()
+	}
+	return true
+}
+
+// restoreSearchBounds recomputes the constraints of the overlap scan as
+// findNextOverlap refines them while descending from the root to the
+// iterator's current position.
+func (i *Iterator[I, K, V]) restoreSearchBounds() {
+	ll := lowLevel(i)
+	var buf [8]int16
+	positions := append(buf[:0], ll.Pos())
+	for ll.Depth() > 0 {
+		ll.Ascend()
+		positions = append(positions, ll.Pos())
+	}
+	i.constrainMinSearchBounds()
+	i.constrainMaxSearchBounds()
+	for j := len(positions) - 1; j > 0; j-- {
+		par, pos := ll.Node(), positions[j]
+		ll.Descend()
+		if par == i.o.constrMinN && pos == i.o.constrMinPos {
+			i.constrainMinSearchBounds()
+		}
+		if par == i.o.constrMaxN && pos == i.o.constrMaxPos {
+			i.constrainMaxSearchBounds()
+		}
+	}
+	ll.SetPos(positions[0])
+}
+
 // NextOverlap positions the iterator to the latch immediately following
 // its current position that overlaps with the search latch.
 func (i *Iterator[I, K, V]) NextOverlap() {
@@ -137,12 +218,10 @@ func (i *Iterator[I, K, V]) NextOverlap() {
 func (i *Iterator[I, K, V]) constrainMinSearchBounds() {
 	ll := lowLevel(i)
 	cfg := ll.Config().Updater.(*updater[I, K, V])
-	cmp := cfg.cmp
-	k := cfg.key(i.o.bounds)
+	// The first interval whose key is at least the key of the bounds.
+	lo := keyBound[K]{k: cfg.key(i.o.bounds)}
 	n := ll.Node()
-	j := sort.Search(int(n.Count()), func(j int) bool {
-		return cmp(k, cfg.key(n.GetKey(int16(j)))) <= 0
-	})
+	j := cfg.searchBound(n.GetKeys(), lo)
 	i.o.constrMinN = n
 	i.o.constrMinPos = int16(j)
 }
@@ -150,12 +229,8 @@ func (i *Iterator[I, K, V]) constrainMinSearchBounds() {
 func (i *Iterator[I, K, V]) constrainMaxSearchBounds() {
 	ll := lowLevel(i)
 	cfg := ll.Config().Updater.(*updater[I, K, V])
-	cmp := cfg.cmp
-	up := cfg.upperBound(i.o.bounds)
 	n := ll.Node()
-	j := sort.Search(int(n.Count()), func(j int) bool {
-		return !up.contains(cmp, cfg.key(n.GetKey(int16(j))))
-	})
+	j := cfg.searchBound(n.GetKeys(), cfg.upperBound(i.o.bounds))
 	i.o.constrMaxN = n
 	i.o.constrMaxPos = int16(j)
 }
@@ -206,7 +281,7 @@ func (i *Iterator[I, K, V]) findNextOverlap() {
 				// span's start key.
 				return
 			}
-			if cfg.upperBound(i.Cur()).contains(cmp, cfg.key(i.o.bounds)) {
+			if cfg.reachesKey(i.Cur(), cfg.key(i.o.bounds)) {
 				return
 			}
 		}
@@ -0,0 +1,217 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package interval
+
+import (
+	"cmp"
+
+	"github.com/ajwerner/btree/internal/abstract"
+)
+
+// RangeMap maps non-overlapping ranges [start, end) of keys of type K to
+// values of type V. Setting the value of a range overwrites the values of
+// the portions of existing ranges which it covers, and adjacent ranges with
+// equal values are merged, so each range is maximal.
+//
+// Unlike Map, a RangeMap needs no augmentation: because its ranges are
+// disjoint, the range containing a key is the last one starting at or
+// before it.
+type RangeMap[K, V any] struct {
+	m   abstract.Map[K, rangeEntry[K, V], struct{}]
+	cmp func(K, K) int
+	eq  func(V, V) bool
+}
+
+// rangeEntry is the end and value of a range in a RangeMap, which is keyed
+// by the range's start.
+type rangeEntry[K, V any] struct {
+	end K
+	v   V
+}
+
+// MakeRangeMap constructs a new RangeMap with the provided comparison
+// function for keys.
+func MakeRangeMap[K any, V comparable](cmpK Cmp[K], opts ...Option) RangeMap[K, V] {
+	return RangeMap[K, V]{
+		m:   abstract.MakeMap[K, rangeEntry[K, V], struct{}](cmpK, nil, opts...),
+		cmp: cmpK,
+		eq:  func(a, b V) bool { return a == b },
+	}
+}
+
+// MakeOrderedRangeMap constructs a new RangeMap for keys with a natural
+// order. It is equivalent to MakeRangeMap with cmp.Compare but searches
+// faster.
+func MakeOrderedRangeMap[K cmp.Ordered, V comparable](opts ...Option) RangeMap[K, V] {
+	return RangeMap[K, V]{
+		m:   abstract.MakeOrderedMap[K, rangeEntry[K, V], struct{}](nil, opts...),
+		cmp: cmp.Compare[K],
+		eq:  func(a, b V) bool { return a == b },
+	}
+}
+
+// Clone clones the RangeMap, lazily. It does so in constant time.
+func (r *RangeMap[K, V]) Clone() RangeMap[K, V] {
+	c := *r
+	c.m = r.m.Clone()
+	return c
+}
+
+// Reset removes all ranges from the RangeMap. In doing so, it allows memory
+// held by the RangeMap to be recycled.
+func (r *RangeMap[K, V]) Reset() {
+	r.m.Reset()
+}
+
+// Len returns the number of ranges in the RangeMap.
+func (r *RangeMap[K, V]) Len() int {
+	return r.m.Len()
+}
+
+// Set associates the keys in [lo, hi) with the value. Existing ranges are
+// split at lo and hi as necessary, and the new range is merged with
+// adjacent ranges which have an equal value. Setting an empty range has no
+// effect. If lo is greater than hi, ErrInvalidInterval is returned.
+func (r *RangeMap[K, V]) Set(lo, hi K, v V) error {
+	if empty, err := r.carve(lo, hi); empty || err != nil {
+		return err
+	}
+	it := r.m.Iterator()
+	it.SeekLT(lo)
+	if it.Valid() && r.cmp(it.Value().end, lo) == 0 && r.eq(it.Value().v, v) {
+		lo = it.Cur()
+	}
+	if next, ok := r.m.Get(hi); ok && r.eq(next.v, v) {
+		r.m.Delete(hi)
+		hi = next.end
+	}
+	r.m.Upsert(lo, rangeEntry[K, V]{end: hi, v: v})
+	return nil
+}
+
+// Delete removes the keys in [lo, hi) from the RangeMap, splitting existing
+// ranges at lo and hi as necessary. If lo is greater than hi,
+// ErrInvalidInterval is returned.
+func (r *RangeMap[K, V]) Delete(lo, hi K) error {
+	_, err := r.carve(lo, hi)
+	return err
+}
+
+// carve removes the keys in [lo, hi) from the RangeMap. It returns true if
+// the range is empty, in which case it does nothing.
+func (r *RangeMap[K, V]) carve(lo, hi K) (empty bool, _ error) {
+	switch c := r.cmp(lo, hi); {
+	case c > 0:
+		return true, ErrInvalidInterval
+	case c == 0:
+		return true, nil
+	}
+	// Truncate the range which contains lo, if any, splitting it in two if
+	// it also contains hi.
+	it := r.m.Iterator()
+	it.SeekLT(lo)
+	if it.Valid() && r.cmp(it.Value().end, lo) > 0 {
+		start, s := it.Cur(), it.Value()
+		r.m.Upsert(start, rangeEntry[K, V]{end: lo, v: s.v})
+		if r.cmp(s.end, hi) > 0 {
+			r.m.Upsert(hi, s)
+			return false, nil
+		}
+	}
+	// Remove the ranges which start within [lo, hi), keeping the portion of
+	// the last one beyond hi.
+	var tail rangeEntry[K, V]
+	var hasTail bool
+	mit := r.m.MutatingIterator()
+	for mit.SeekGE(lo); mit.Valid() && r.cmp(mit.Cur(), hi) < 0; {
+		if s := mit.Value(); r.cmp(s.end, hi) > 0 {
+			tail, hasTail = s, true
+		}
+		mit.DeleteCurrent()
+	}
+	if hasTail {
+		r.m.Upsert(hi, tail)
+	}
+	return false, nil
+}
+
+// Get returns the value of the range containing the key, if any.
+func (r *RangeMap[K, V]) Get(k K) (v V, ok bool) {
+	_, _, v, ok = r.GetRange(k)
+	return v, ok
+}
+
+// GetRange returns the bounds and value of the range containing the key, if
+// any.
+func (r *RangeMap[K, V]) GetRange(k K) (lo, hi K, v V, ok bool) {
+	it := r.Iterator()
+	if it.SeekGE(k); !it.Valid() || r.cmp(it.Start(), k) > 0 {
+		return lo, hi, v, false
+	}
+	return it.Start(), it.End(), it.Value(), true
+}
+
+// Iterator constructs a new RangeIterator for the RangeMap.
+func (r *RangeMap[K, V]) Iterator() RangeIterator[K, V] {
+	return RangeIterator[K, V]{it: r.m.Iterator(), cmp: r.cmp}
+}
+
+// RangeIterator is an iterator over the ranges of a RangeMap in order.
+type RangeIterator[K, V any] struct {
+	it  abstract.Iterator[K, rangeEntry[K, V], struct{}]
+	cmp func(K, K) int
+}
+
+// Reset marks the iterator as invalid.
+func (i *RangeIterator[K, V]) Reset() { i.it.Reset() }
+
+// First seeks to the first range in the RangeMap.
+func (i *RangeIterator[K, V]) First() { i.it.First() }
+
+// Last seeks to the last range in the RangeMap.
+func (i *RangeIterator[K, V]) Last() { i.it.Last() }
+
+// Next positions the iterator to the following range.
+func (i *RangeIterator[K, V]) Next() { i.it.Next() }
+
+// Prev positions the iterator to the previous range.
+func (i *RangeIterator[K, V]) Prev() { i.it.Prev() }
+
+// Valid returns whether the iterator is positioned at a valid position.
+func (i *RangeIterator[K, V]) Valid() bool { return i.it.Valid() }
+
+// SeekGE seeks to the range containing the key or, if there is none, to the
+// first range which starts after it.
+func (i *RangeIterator[K, V]) SeekGE(k K) {
+	if i.it.SeekLT(k); i.it.Valid() && i.cmp(i.End(), k) > 0 {
+		return
+	}
+	i.it.SeekGE(k)
+}
+
+// SeekLT seeks to the last range which starts before the key.
+func (i *RangeIterator[K, V]) SeekLT(k K) { i.it.SeekLT(k) }
+
+// Start returns the inclusive start of the range at the iterator's current
+// position. It is illegal to call Start if the iterator is not valid.
+func (i *RangeIterator[K, V]) Start() K { return i.it.Cur() }
+
+// End returns the exclusive end of the range at the iterator's current
+// position. It is illegal to call End if the iterator is not valid.
+func (i *RangeIterator[K, V]) End() K { return i.it.Value().end }
+
+// Value returns the value of the range at the iterator's current position.
+// It is illegal to call Value if the iterator is not valid.
+func (i *RangeIterator[K, V]) Value() V { return i.it.Value().v }
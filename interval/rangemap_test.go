@@ -0,0 +1,119 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package interval
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type rangeVal struct {
+	lo, hi int
+	v      string
+}
+
+func ranges(r *RangeMap[int, string]) (rs []rangeVal) {
+	it := r.Iterator()
+	for it.First(); it.Valid(); it.Next() {
+		rs = append(rs, rangeVal{it.Start(), it.End(), it.Value()})
+	}
+	return rs
+}
+
+func TestRangeMap(t *testing.T) {
+	r := MakeOrderedRangeMap[int, string]()
+	require.NoError(t, r.Set(0, 10, "a"))
+	require.NoError(t, r.Set(20, 30, "b"))
+	require.NoError(t, r.Set(5, 25, "c"))
+	require.Equal(t, []rangeVal{{0, 5, "a"}, {5, 25, "c"}, {25, 30, "b"}}, ranges(&r))
+
+	// Overwriting the middle of a range splits it.
+	require.NoError(t, r.Set(10, 15, "d"))
+	require.Equal(t, []rangeVal{
+		{0, 5, "a"}, {5, 10, "c"}, {10, 15, "d"}, {15, 25, "c"}, {25, 30, "b"},
+	}, ranges(&r))
+
+	// Setting an equal value merges adjacent ranges.
+	require.NoError(t, r.Set(10, 15, "c"))
+	require.Equal(t, []rangeVal{{0, 5, "a"}, {5, 25, "c"}, {25, 30, "b"}}, ranges(&r))
+	require.NoError(t, r.Set(25, 40, "c"))
+	require.Equal(t, []rangeVal{{0, 5, "a"}, {5, 40, "c"}}, ranges(&r))
+
+	v, ok := r.Get(39)
+	require.True(t, ok)
+	require.Equal(t, "c", v)
+	_, ok = r.Get(40)
+	require.False(t, ok)
+	lo, hi, _, ok := r.GetRange(5)
+	require.True(t, ok)
+	require.Equal(t, [2]int{5, 40}, [2]int{lo, hi})
+
+	require.NoError(t, r.Delete(3, 7))
+	require.Equal(t, []rangeVal{{0, 3, "a"}, {7, 40, "c"}}, ranges(&r))
+	require.NoError(t, r.Set(1, 1, "e"))
+	require.True(t, errors.Is(r.Set(2, 1, "e"), ErrInvalidInterval))
+	require.True(t, errors.Is(r.Delete(2, 1), ErrInvalidInterval))
+	require.Equal(t, []rangeVal{{0, 3, "a"}, {7, 40, "c"}}, ranges(&r))
+
+	it := r.Iterator()
+	it.SeekGE(5)
+	require.Equal(t, 7, it.Start())
+	it.SeekGE(2)
+	require.Equal(t, 0, it.Start())
+}
+
+func TestRangeMapRandomized(t *testing.T) {
+	rng := newRand(t)
+	const n = 200
+	var ref [n]string
+	r := MakeRangeMap[int, string](func(a, b int) int { return a - b })
+	for i := 0; i < 2000; i++ {
+		lo := rng.Intn(n)
+		hi := lo + rng.Intn(n-lo+1)
+		v := string(rune('a' + rng.Intn(4)))
+		if rng.Intn(4) == 0 {
+			v = ""
+			require.NoError(t, r.Delete(lo, hi))
+		} else {
+			require.NoError(t, r.Set(lo, hi, v))
+		}
+		for k := lo; k < hi; k++ {
+			ref[k] = v
+		}
+		if i%10 == 0 {
+			clone := r.Clone()
+			clone.Set(0, n, "z")
+		}
+		// Compute the maximal ranges of the reference.
+		var want []rangeVal
+		for k := 0; k < n; k++ {
+			if ref[k] == "" {
+				continue
+			}
+			if l := len(want); l > 0 && want[l-1].hi == k && want[l-1].v == ref[k] {
+				want[l-1].hi++
+			} else {
+				want = append(want, rangeVal{k, k + 1, ref[k]})
+			}
+		}
+		require.Equal(t, want, ranges(&r))
+		k := rng.Intn(n)
+		v, ok := r.Get(k)
+		require.Equal(t, ref[k] != "", ok)
+		require.Equal(t, ref[k], v)
+	}
+}
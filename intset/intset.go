@@ -0,0 +1,334 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package intset provides a set of integers stored as maximal runs of
+// consecutive integers, suited to sets which are mostly contiguous.
+package intset
+
+import (
+	"cmp"
+	"unsafe"
+
+	"github.com/ajwerner/btree/internal/abstract"
+)
+
+// Integer is the set of integer types which may be stored in a Set.
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// Set is a set of integers. The integers are stored as disjoint, maximal
+// runs [lo, last], so that a contiguous range of any length occupies a single
+// entry in the tree. Each subtree tracks the number of integers in its runs,
+// so the cardinality of the Set is available in constant time.
+//
+// Ranges are passed to AddRange and RemoveRange as half-open intervals
+// [lo, hi), which cannot include the largest value of T; Add and Remove
+// operate on any single integer, including that one.
+type Set[T Integer] struct {
+	m abstract.Map[run[T], struct{}, card]
+}
+
+// run is a run of consecutive integers [lo, last]. The last integer is
+// inclusive so that a run may contain the largest value of T. Runs are
+// ordered by lo alone.
+type run[T Integer] struct {
+	lo, last T
+}
+
+// size returns the number of integers in the run. The arithmetic is
+// performed modulo 2^64 so that it does not overflow for signed types.
+func (r run[T]) size() uint64 {
+	return uint64(r.last) - uint64(r.lo) + 1
+}
+
+// card is the augmentation of a Set.
+type card struct {
+	// n is the number of integers in the runs of the current subtree.
+	n uint64
+}
+
+// Option configures a Set. See btree.WithAllocator.
+type Option = abstract.Option
+
+// MakeSet constructs a new, empty Set.
+func MakeSet[T Integer](opts ...Option) Set[T] {
+	return Set[T]{
+		m: abstract.MakeMap[run[T], struct{}, card](
+			func(a, b run[T]) int { return cmp.Compare(a.lo, b.lo) },
+			cardUpdater[T]{},
+			opts...,
+		),
+	}
+}
+
+// Clone clones the Set, lazily. It does so in constant time.
+func (s *Set[T]) Clone() Set[T] {
+	return Set[T]{m: s.m.Clone()}
+}
+
+// Reset removes all integers from the Set. In doing so, it allows memory
+// held by the Set to be recycled.
+func (s *Set[T]) Reset() {
+	s.m.Reset()
+}
+
+// Cardinality returns the number of integers in the Set. The count is
+// computed modulo 2^64, so a Set holding every value of a 64-bit type
+// reports 0.
+func (s *Set[T]) Cardinality() uint64 {
+	it := s.m.Iterator()
+	if n := abstract.LowLevel(&it).Node(); n != nil {
+		return n.GetA().n
+	}
+	return 0
+}
+
+// NumRuns returns the number of maximal runs of consecutive integers in the
+// Set.
+func (s *Set[T]) NumRuns() int {
+	return s.m.Len()
+}
+
+// Contains returns whether the integer is in the Set.
+func (s *Set[T]) Contains(x T) bool {
+	it := s.Iterator()
+	it.SeekGE(x)
+	return it.Valid() && it.Start() <= x
+}
+
+// NextAbsent returns the smallest integer greater-than or equal to x which
+// is not in the Set. It returns false if the Set contains every integer from
+// x up to the largest value of T.
+func (s *Set[T]) NextAbsent(x T) (_ T, ok bool) {
+	it := s.Iterator()
+	if it.SeekGE(x); it.Valid() && it.Start() <= x {
+		if it.Max() == maxValue[T]() {
+			return 0, false
+		}
+		return it.Max() + 1, true
+	}
+	return x, true
+}
+
+// Add adds the integer to the Set.
+func (s *Set[T]) Add(x T) {
+	s.addRun(run[T]{lo: x, last: x})
+}
+
+// Remove removes the integer from the Set.
+func (s *Set[T]) Remove(x T) {
+	s.removeRun(run[T]{lo: x, last: x})
+}
+
+// AddRange adds the integers in [lo, hi) to the Set. If lo is not less than
+// hi, the range is empty and AddRange has no effect.
+func (s *Set[T]) AddRange(lo, hi T) {
+	if lo < hi {
+		s.addRun(run[T]{lo: lo, last: hi - 1})
+	}
+}
+
+// RemoveRange removes the integers in [lo, hi) from the Set. If lo is not
+// less than hi, the range is empty and RemoveRange has no effect.
+func (s *Set[T]) RemoveRange(lo, hi T) {
+	if lo < hi {
+		s.removeRun(run[T]{lo: lo, last: hi - 1})
+	}
+}
+
+// addRun adds the integers in r to the Set.
+func (s *Set[T]) addRun(r run[T]) {
+	// Absorb the run which contains or ends just before r.lo, if any, and
+	// every run which starts within r or just after it. The comparisons are
+	// arranged so that they do not overflow at the bounds of T.
+	it := s.m.MutatingIterator()
+	if it.SeekLT(r); it.Valid() && (it.Cur().last >= r.lo || it.Cur().last+1 == r.lo) {
+		r = run[T]{lo: it.Cur().lo, last: max(r.last, it.Cur().last)}
+		it.DeleteCurrent()
+	} else {
+		it.SeekGE(r)
+	}
+	for ; it.Valid() && (it.Cur().lo <= r.last || it.Cur().lo-1 == r.last); it.DeleteCurrent() {
+		r.last = max(r.last, it.Cur().last)
+	}
+	s.m.Upsert(r, struct{}{})
+}
+
+// removeRun removes the integers in r from the Set.
+func (s *Set[T]) removeRun(r run[T]) {
+	// Remove every run which overlaps r, keeping the portions which lie
+	// outside of it.
+	var keep [2]run[T]
+	var keepBefore, keepAfter bool
+	it := s.m.MutatingIterator()
+	if it.SeekLT(r); it.Valid() && it.Cur().last >= r.lo {
+		keep[0], keepBefore = run[T]{lo: it.Cur().lo, last: r.lo - 1}, true
+		if it.Cur().last > r.last {
+			keep[1], keepAfter = run[T]{lo: r.last + 1, last: it.Cur().last}, true
+		}
+		it.DeleteCurrent()
+	} else {
+		it.SeekGE(r)
+	}
+	for ; it.Valid() && it.Cur().lo <= r.last; it.DeleteCurrent() {
+		if it.Cur().last > r.last {
+			keep[1], keepAfter = run[T]{lo: r.last + 1, last: it.Cur().last}, true
+		}
+	}
+	if keepBefore {
+		s.m.Upsert(keep[0], struct{}{})
+	}
+	if keepAfter {
+		s.m.Upsert(keep[1], struct{}{})
+	}
+}
+
+// Union adds the integers in o to the Set.
+func (s *Set[T]) Union(o *Set[T]) {
+	// Iterate over a clone in case o is s.
+	c := o.Clone()
+	it := c.Iterator()
+	for it.First(); it.Valid(); it.Next() {
+		s.addRun(run[T]{lo: it.Start(), last: it.Max()})
+	}
+}
+
+// Intersect removes the integers which are not in o from the Set.
+func (s *Set[T]) Intersect(o *Set[T]) {
+	if s.NumRuns() == 0 {
+		return
+	}
+	if o.NumRuns() == 0 {
+		s.Reset()
+		return
+	}
+	// Remove each gap between the runs of o, along with the integers before
+	// its first run and after its last. Next is the first integer which
+	// follows the previous run of o.
+	c := o.Clone()
+	sit, it := s.Iterator(), c.Iterator()
+	sit.First()
+	next := sit.Start()
+	for it.First(); it.Valid(); it.Next() {
+		if it.Start() > next {
+			s.removeRun(run[T]{lo: next, last: it.Start() - 1})
+		}
+		if it.Max() == maxValue[T]() {
+			return
+		}
+		next = it.Max() + 1
+	}
+	sit = s.Iterator()
+	if sit.Last(); sit.Valid() && sit.Max() >= next {
+		s.removeRun(run[T]{lo: next, last: sit.Max()})
+	}
+}
+
+// maxValue returns the largest value of T.
+func maxValue[T Integer]() T {
+	// For unsigned types, ^T(0) is the largest value. For signed types it is
+	// -1, and the largest value is found by clearing the sign bit.
+	if m := ^T(0); m > 0 {
+		return m
+	}
+	return T(^uint64(0) >> (65 - 8*unsafe.Sizeof(T(0))))
+}
+
+// Iterator constructs a new Iterator over the runs of the Set.
+func (s *Set[T]) Iterator() Iterator[T] {
+	return Iterator[T]{it: s.m.Iterator()}
+}
+
+// Iterator is an iterator over the maximal runs of a Set in order.
+type Iterator[T Integer] struct {
+	it abstract.Iterator[run[T], struct{}, card]
+}
+
+// Reset marks the iterator as invalid.
+func (i *Iterator[T]) Reset() { i.it.Reset() }
+
+// First seeks to the first run in the Set.
+func (i *Iterator[T]) First() { i.it.First() }
+
+// Last seeks to the last run in the Set.
+func (i *Iterator[T]) Last() { i.it.Last() }
+
+// Next positions the iterator to the following run.
+func (i *Iterator[T]) Next() { i.it.Next() }
+
+// Prev positions the iterator to the previous run.
+func (i *Iterator[T]) Prev() { i.it.Prev() }
+
+// Valid returns whether the iterator is positioned at a valid position.
+func (i *Iterator[T]) Valid() bool { return i.it.Valid() }
+
+// SeekGE seeks to the run containing x or, if there is none, to the first
+// run which starts after it.
+func (i *Iterator[T]) SeekGE(x T) {
+	if i.it.SeekLT(run[T]{lo: x}); i.it.Valid() && i.Max() >= x {
+		return
+	}
+	i.it.SeekGE(run[T]{lo: x})
+}
+
+// Start returns the smallest integer in the run at the iterator's current
+// position. It is illegal to call Start if the iterator is not valid.
+func (i *Iterator[T]) Start() T { return i.it.Cur().lo }
+
+// End returns the exclusive end of the run at the iterator's current
+// position. If the run contains the largest value of T, End wraps around to
+// the smallest; use Max for such runs. It is illegal to call End if the
+// iterator is not valid.
+func (i *Iterator[T]) End() T { return i.it.Cur().last + 1 }
+
+// Max returns the largest integer in the run at the iterator's current
+// position. It is illegal to call Max if the iterator is not valid.
+func (i *Iterator[T]) Max() T { return i.it.Cur().last }
+
+type cardUpdater[T Integer] struct{}
+
+func (cardUpdater[T]) Update(
+	n *abstract.Node[run[T], struct{}, card],
+	md abstract.UpdateInfo[run[T], card],
+) (updated bool) {
+	a := n.GetA()
+	switch md.Action {
+	case abstract.Removal, abstract.Split:
+		a.n -= md.RelevantKey.size()
+		if md.ModifiedOther != nil {
+			a.n -= md.ModifiedOther.n
+		}
+		return true
+	case abstract.Insertion:
+		a.n += md.RelevantKey.size()
+		if md.ModifiedOther != nil {
+			a.n += md.ModifiedOther.n
+		}
+		return true
+	default:
+		orig := a.n
+		a.n = 0
+		for i := int16(0); i <= n.Count(); i++ {
+			if i < n.Count() {
+				a.n += n.GetKey(i).size()
+			}
+			if c := n.GetChild(i); c != nil {
+				a.n += c.n
+			}
+		}
+		return a.n != orig
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package intset
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func runs[T Integer](s *Set[T]) (rs [][2]T) {
+	it := s.Iterator()
+	for it.First(); it.Valid(); it.Next() {
+		rs = append(rs, [2]T{it.Start(), it.End()})
+	}
+	return rs
+}
+
+// closedRuns is like runs but reports the inclusive last integer of each run,
+// which is representable even when the run contains the largest value of T.
+func closedRuns[T Integer](s *Set[T]) (rs [][2]T) {
+	it := s.Iterator()
+	for it.First(); it.Valid(); it.Next() {
+		rs = append(rs, [2]T{it.Start(), it.Max()})
+	}
+	return rs
+}
+
+func nextAbsent[T Integer](t *testing.T, s *Set[T], x T) T {
+	t.Helper()
+	y, ok := s.NextAbsent(x)
+	require.True(t, ok)
+	return y
+}
+
+func TestSet(t *testing.T) {
+	s := MakeSet[int]()
+	s.AddRange(0, 10)
+	s.AddRange(20, 30)
+	s.AddRange(10, 15)
+	require.Equal(t, [][2]int{{0, 15}, {20, 30}}, runs(&s))
+	s.AddRange(15, 20)
+	require.Equal(t, [][2]int{{0, 30}}, runs(&s))
+	s.RemoveRange(5, 8)
+	s.RemoveRange(40, 50)
+	require.Equal(t, [][2]int{{0, 5}, {8, 30}}, runs(&s))
+	require.Equal(t, uint64(27), s.Cardinality())
+	require.True(t, s.Contains(4))
+	require.False(t, s.Contains(5))
+	require.Equal(t, 5, nextAbsent(t, &s, 0))
+	require.Equal(t, 6, nextAbsent(t, &s, 6))
+	require.Equal(t, 30, nextAbsent(t, &s, 8))
+
+	o := MakeSet[int]()
+	o.AddRange(3, 10)
+	o.AddRange(25, 40)
+	c := s.Clone()
+	c.Intersect(&o)
+	require.Equal(t, [][2]int{{3, 5}, {8, 10}, {25, 30}}, runs(&c))
+	require.Equal(t, uint64(27), s.Cardinality())
+	s.Union(&o)
+	require.Equal(t, [][2]int{{0, 40}}, runs(&s))
+	s.Union(&s)
+	s.Intersect(&s)
+	require.Equal(t, [][2]int{{0, 40}}, runs(&s))
+}
+
+func TestSetExtremes(t *testing.T) {
+	s := MakeSet[int8]()
+	s.AddRange(math.MinInt8, math.MaxInt8)
+	require.Equal(t, uint64(255), s.Cardinality())
+	require.Equal(t, int8(math.MaxInt8), nextAbsent(t, &s, 0))
+	s.RemoveRange(-1, 1)
+	require.Equal(t, uint64(253), s.Cardinality())
+	require.Equal(t, [][2]int8{{math.MinInt8, -1}, {1, math.MaxInt8}}, runs(&s))
+	s.Add(math.MaxInt8)
+	require.Equal(t, [][2]int8{{math.MinInt8, -2}, {1, math.MaxInt8}}, closedRuns(&s))
+	_, ok := s.NextAbsent(1)
+	require.False(t, ok)
+}
+
+func TestSetMaxUint8(t *testing.T) {
+	s := MakeSet[uint8]()
+	s.Add(math.MaxUint8)
+	require.True(t, s.Contains(math.MaxUint8))
+	require.False(t, s.Contains(math.MaxUint8-1))
+	require.Equal(t, uint64(1), s.Cardinality())
+	_, ok := s.NextAbsent(math.MaxUint8)
+	require.False(t, ok)
+
+	// A range ending just before the maximum merges with it.
+	s.AddRange(250, math.MaxUint8)
+	require.Equal(t, [][2]uint8{{250, math.MaxUint8}}, closedRuns(&s))
+	require.Equal(t, uint64(6), s.Cardinality())
+	require.Equal(t, uint8(249), nextAbsent(t, &s, 249))
+	require.Equal(t, uint8(0), nextAbsent(t, &s, 0))
+
+	// The whole domain is a single run.
+	s.AddRange(0, 250)
+	require.Equal(t, [][2]uint8{{0, math.MaxUint8}}, closedRuns(&s))
+	require.Equal(t, uint64(256), s.Cardinality())
+	_, ok = s.NextAbsent(0)
+	require.False(t, ok)
+
+	o := MakeSet[uint8]()
+	o.Add(0)
+	o.Add(math.MaxUint8)
+	c := s.Clone()
+	c.Intersect(&o)
+	require.Equal(t, [][2]uint8{{0, 0}, {math.MaxUint8, math.MaxUint8}}, closedRuns(&c))
+
+	s.Remove(math.MaxUint8)
+	require.False(t, s.Contains(math.MaxUint8))
+	require.Equal(t, uint8(math.MaxUint8), nextAbsent(t, &s, 0))
+	s.Union(&c)
+	require.Equal(t, uint64(256), s.Cardinality())
+	s.RemoveRange(0, math.MaxUint8)
+	require.Equal(t, [][2]uint8{{math.MaxUint8, math.MaxUint8}}, closedRuns(&s))
+}
+
+func TestSetRandomized(t *testing.T) {
+	rng := newRand(t)
+	// The sets cover every uint8 so that runs reach the largest value.
+	const n = math.MaxUint8 + 1
+	type bitmap [n]bool
+	randomize := func(s *Set[uint8], ref *bitmap, ops int) {
+		for i := 0; i < ops; i++ {
+			lo := rng.Intn(n)
+			hi := min(lo+rng.Intn(20), n)
+			add := rng.Intn(3) > 0
+			switch {
+			case hi == lo:
+			case add && hi < n:
+				s.AddRange(uint8(lo), uint8(hi))
+			case !add && hi < n:
+				s.RemoveRange(uint8(lo), uint8(hi))
+			case add:
+				s.AddRange(uint8(lo), uint8(hi-1))
+				s.Add(uint8(hi - 1))
+			default:
+				s.RemoveRange(uint8(lo), uint8(hi-1))
+				s.Remove(uint8(hi - 1))
+			}
+			for x := lo; x < hi; x++ {
+				ref[x] = add
+			}
+		}
+	}
+	check := func(s *Set[uint8], ref *bitmap) {
+		var card uint64
+		var want [][2]uint8
+		for x := 0; x < n; x++ {
+			require.Equal(t, ref[x], s.Contains(uint8(x)))
+			if !ref[x] {
+				continue
+			}
+			card++
+			if l := len(want); l > 0 && int(want[l-1][1]) == x-1 {
+				want[l-1][1]++
+			} else {
+				want = append(want, [2]uint8{uint8(x), uint8(x)})
+			}
+		}
+		require.Equal(t, want, closedRuns(s))
+		require.Equal(t, card, s.Cardinality())
+		for x := 0; x < n; x++ {
+			y := n
+			if v, ok := s.NextAbsent(uint8(x)); ok {
+				y = int(v)
+			}
+			require.False(t, y < n && ref[y])
+			for z := x; z < y; z++ {
+				require.True(t, ref[z])
+			}
+		}
+	}
+	for i := 0; i < 50; i++ {
+		var refA, refB bitmap
+		a, b := MakeSet[uint8](), MakeSet[uint8]()
+		randomize(&a, &refA, 100)
+		randomize(&b, &refB, 100)
+		check(&a, &refA)
+		u, in := a.Clone(), a.Clone()
+		u.Union(&b)
+		in.Intersect(&b)
+		var refU, refI bitmap
+		for x := range refA {
+			refU[x] = refA[x] || refB[x]
+			refI[x] = refA[x] && refB[x]
+		}
+		check(&u, &refU)
+		check(&in, &refI)
+		check(&a, &refA)
+	}
+}
+
+// newRand returns a source of randomness for a test, logging its seed so
+// that a failure can be reproduced.
+func newRand(t testing.TB) *rand.Rand {
+	t.Helper()
+	seed := time.Now().UnixNano()
+	t.Logf("seed: %d", seed)
+	return rand.New(rand.NewSource(seed))
+}
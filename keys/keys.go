@@ -0,0 +1,318 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package keys encodes tuples of values into byte strings whose bytewise
+// order matches the order of the tuples. A map keyed by encoded tuples can
+// use bytes.Compare, or be a bytekey.Map, rather than a comparison function
+// for each tuple type. Because the encoding of a tuple begins with the
+// encoding of each of its prefixes, the keys sharing leading fields can be
+// scanned by seeking to the encoded prefix.
+//
+// Each field is encoded as a tag byte identifying its type followed by its
+// payload. A field may be encoded in descending order, in which case all of
+// its bytes are complemented. Fields of different types order by their tags.
+package keys
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Direction is the order in which a field is encoded.
+type Direction bool
+
+const (
+	// Ascending encodes a field such that smaller values order first.
+	Ascending Direction = false
+	// Descending encodes a field such that larger values order first.
+	Descending Direction = true
+)
+
+// ErrInvalidKey is returned when decoding a malformed key.
+var ErrInvalidKey = errors.New("keys: invalid key")
+
+// The tags of the encoded types. The complement of each tag, which marks a
+// descending field, is distinct from every tag.
+const (
+	tagFalse  byte = 0x10
+	tagTrue   byte = 0x11
+	tagInt    byte = 0x20
+	tagUint   byte = 0x21
+	tagFloat  byte = 0x22
+	tagTime   byte = 0x30
+	tagBytes  byte = 0x40
+	tagString byte = 0x41
+)
+
+// The bytes which terminate and escape the payload of a string or []byte.
+// A zero byte in the payload is encoded as escape0 escapeFF, and the
+// payload ends with escape0 terminator.
+const (
+	escape0    byte = 0x00
+	escapeFF   byte = 0xff
+	terminator byte = 0x01
+)
+
+// AppendBool appends the encoding of a bool to dst.
+func AppendBool(dst []byte, v bool, dir Direction) []byte {
+	tag := tagFalse
+	if v {
+		tag = tagTrue
+	}
+	return append(dst, tag^mask(dir))
+}
+
+// AppendInt appends the encoding of an integer to dst.
+func AppendInt(dst []byte, v int64, dir Direction) []byte {
+	return appendUint64(dst, tagInt, uint64(v)^(1<<63), dir)
+}
+
+// AppendUint appends the encoding of an unsigned integer to dst.
+func AppendUint(dst []byte, v uint64, dir Direction) []byte {
+	return appendUint64(dst, tagUint, v, dir)
+}
+
+// AppendFloat appends the encoding of a float to dst. Negative zero is
+// encoded as zero, and every NaN is encoded as the same NaN, which orders
+// after positive infinity.
+func AppendFloat(dst []byte, v float64, dir Direction) []byte {
+	if v == 0 {
+		v = 0
+	}
+	if v != v {
+		v = math.NaN()
+	}
+	bits := math.Float64bits(v)
+	if bits&(1<<63) != 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+	return appendUint64(dst, tagFloat, bits, dir)
+}
+
+// AppendTime appends the encoding of a time to dst. The time's location is
+// not encoded; it decodes in UTC.
+func AppendTime(dst []byte, v time.Time, dir Direction) []byte {
+	m := mask(dir)
+	dst = append(dst, tagTime^m)
+	dst = binary.BigEndian.AppendUint64(dst, (uint64(v.Unix())^(1<<63))^uint64(m)*0x0101010101010101)
+	return binary.BigEndian.AppendUint32(dst, uint32(v.Nanosecond())^uint32(m)*0x01010101)
+}
+
+// AppendString appends the encoding of a string to dst.
+func AppendString(dst []byte, v string, dir Direction) []byte {
+	return appendBytes(dst, tagString, v, dir)
+}
+
+// AppendBytes appends the encoding of a byte slice to dst. It orders
+// identically to the same bytes encoded by AppendString, but decodes as a
+// []byte.
+func AppendBytes(dst []byte, v []byte, dir Direction) []byte {
+	return appendBytes(dst, tagBytes, v, dir)
+}
+
+func appendUint64(dst []byte, tag byte, v uint64, dir Direction) []byte {
+	m := mask(dir)
+	dst = append(dst, tag^m)
+	return binary.BigEndian.AppendUint64(dst, v^uint64(m)*0x0101010101010101)
+}
+
+func appendBytes[B ~string | ~[]byte](dst []byte, tag byte, v B, dir Direction) []byte {
+	m := mask(dir)
+	dst = append(dst, tag^m)
+	for i := 0; i < len(v); i++ {
+		if v[i] == escape0 {
+			dst = append(dst, escape0^m, escapeFF^m)
+		} else {
+			dst = append(dst, v[i]^m)
+		}
+	}
+	return append(dst, escape0^m, terminator^m)
+}
+
+// mask returns the byte with which each byte of a field is exclusive-ored.
+func mask(dir Direction) byte {
+	if dir == Descending {
+		return 0xff
+	}
+	return 0
+}
+
+// Desc wraps a value passed to Encode or Append so that it is encoded in
+// descending order.
+func Desc(v any) any {
+	return desc{v}
+}
+
+type desc struct{ v any }
+
+// Encode encodes the values as a tuple. See Append.
+func Encode(vals ...any) ([]byte, error) {
+	return Append(nil, vals...)
+}
+
+// Append appends the encoding of the values as a tuple to dst. The values
+// may be of any integer or float type, string, []byte, time.Time or bool,
+// and may be wrapped with Desc. Other types result in an error.
+func Append(dst []byte, vals ...any) ([]byte, error) {
+	for _, v := range vals {
+		dir := Ascending
+		if d, ok := v.(desc); ok {
+			v, dir = d.v, Descending
+		}
+		switch v := v.(type) {
+		case bool:
+			dst = AppendBool(dst, v, dir)
+		case int:
+			dst = AppendInt(dst, int64(v), dir)
+		case int8:
+			dst = AppendInt(dst, int64(v), dir)
+		case int16:
+			dst = AppendInt(dst, int64(v), dir)
+		case int32:
+			dst = AppendInt(dst, int64(v), dir)
+		case int64:
+			dst = AppendInt(dst, v, dir)
+		case uint:
+			dst = AppendUint(dst, uint64(v), dir)
+		case uint8:
+			dst = AppendUint(dst, uint64(v), dir)
+		case uint16:
+			dst = AppendUint(dst, uint64(v), dir)
+		case uint32:
+			dst = AppendUint(dst, uint64(v), dir)
+		case uint64:
+			dst = AppendUint(dst, v, dir)
+		case float32:
+			dst = AppendFloat(dst, float64(v), dir)
+		case float64:
+			dst = AppendFloat(dst, v, dir)
+		case string:
+			dst = AppendString(dst, v, dir)
+		case []byte:
+			dst = AppendBytes(dst, v, dir)
+		case time.Time:
+			dst = AppendTime(dst, v, dir)
+		default:
+			return dst, fmt.Errorf("keys: cannot encode value of type %T", v)
+		}
+	}
+	return dst, nil
+}
+
+// Decode decodes all of the fields of an encoded tuple. Each field decodes
+// as an int64, uint64, float64, string, []byte, time.Time or bool.
+func Decode(key []byte) ([]any, error) {
+	var vals []any
+	for len(key) > 0 {
+		v, _, rest, err := DecodeField(key)
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, v)
+		key = rest
+	}
+	return vals, nil
+}
+
+// DecodeField decodes the first field of an encoded tuple. It returns the
+// field's value and direction and the remainder of the key.
+func DecodeField(key []byte) (v any, dir Direction, rest []byte, err error) {
+	if len(key) == 0 {
+		return nil, dir, key, fmt.Errorf("%w: empty", ErrInvalidKey)
+	}
+	first, tag := key[0], key[0]
+	if tag >= 0x80 {
+		tag, dir = ^tag, Descending
+	}
+	m := mask(dir)
+	key = key[1:]
+	switch tag {
+	case tagFalse, tagTrue:
+		return tag == tagTrue, dir, key, nil
+	case tagInt, tagUint, tagFloat:
+		if len(key) < 8 {
+			return nil, dir, key, fmt.Errorf("%w: truncated number", ErrInvalidKey)
+		}
+		u := binary.BigEndian.Uint64(key) ^ uint64(m)*0x0101010101010101
+		switch tag {
+		case tagInt:
+			v = int64(u ^ (1 << 63))
+		case tagUint:
+			v = u
+		default:
+			if u&(1<<63) != 0 {
+				u &^= 1 << 63
+			} else {
+				u = ^u
+			}
+			v = math.Float64frombits(u)
+		}
+		return v, dir, key[8:], nil
+	case tagTime:
+		if len(key) < 12 {
+			return nil, dir, key, fmt.Errorf("%w: truncated time", ErrInvalidKey)
+		}
+		sec := int64(binary.BigEndian.Uint64(key) ^ uint64(m)*0x0101010101010101 ^ (1 << 63))
+		nsec := int64(binary.BigEndian.Uint32(key[8:]) ^ uint32(m)*0x01010101)
+		return time.Unix(sec, nsec).UTC(), dir, key[12:], nil
+	case tagBytes, tagString:
+		var b []byte
+		for i := 0; ; i++ {
+			if i+1 >= len(key) {
+				return nil, dir, key, fmt.Errorf("%w: unterminated string", ErrInvalidKey)
+			}
+			if c := key[i] ^ m; c != escape0 {
+				b = append(b, c)
+				continue
+			}
+			switch key[i+1] ^ m {
+			case escapeFF:
+				b = append(b, escape0)
+				i++
+			case terminator:
+				if tag == tagString {
+					return string(b), dir, key[i+2:], nil
+				}
+				if b == nil {
+					b = []byte{}
+				}
+				return b, dir, key[i+2:], nil
+			default:
+				return nil, dir, key, fmt.Errorf("%w: invalid escape", ErrInvalidKey)
+			}
+		}
+	default:
+		return nil, dir, key, fmt.Errorf("%w: unknown tag %#x", ErrInvalidKey, first)
+	}
+}
+
+// PrefixEnd returns the smallest key which is greater than every key
+// beginning with the prefix, for use as the exclusive end of a scan over
+// those keys. If there is no such key, as when the prefix is empty or
+// consists only of 0xff bytes, it returns nil.
+func PrefixEnd(prefix []byte) []byte {
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] != 0xff {
+			end := append([]byte(nil), prefix[:i+1]...)
+			end[i]++
+			return end
+		}
+	}
+	return nil
+}
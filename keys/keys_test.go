@@ -0,0 +1,171 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package keys
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/ajwerner/btree"
+	"github.com/stretchr/testify/require"
+)
+
+type tuple struct {
+	i int64
+	s string // descending
+	f float64
+	b bool
+	t time.Time // descending
+	u []byte
+}
+
+func (t tuple) encode() []byte {
+	k, err := Encode(t.i, Desc(t.s), t.f, t.b, Desc(t.t), t.u)
+	if err != nil {
+		panic(err)
+	}
+	return k
+}
+
+func compareTuples(a, b tuple) int {
+	boolInt := func(b bool) int {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	if c := cmp.Compare(a.i, b.i); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(b.s, a.s); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.f, b.f); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(boolInt(a.b), boolInt(b.b)); c != 0 {
+		return c
+	}
+	if c := b.t.Compare(a.t); c != 0 {
+		return c
+	}
+	return bytes.Compare(a.u, b.u)
+}
+
+func randomTuple(rng *rand.Rand) tuple {
+	randBytes := func() []byte {
+		b := make([]byte, rng.Intn(4))
+		for i := range b {
+			b[i] = []byte{0, 1, 'a', 0xff}[rng.Intn(4)]
+		}
+		return b
+	}
+	return tuple{
+		i: []int64{math.MinInt64, -1, 0, 1, math.MaxInt64}[rng.Intn(5)],
+		s: string(randBytes()),
+		f: []float64{math.Inf(-1), -1.5, -0.25, 0, 0.25, 1.5, math.Inf(1)}[rng.Intn(7)],
+		b: rng.Intn(2) == 0,
+		t: time.Unix(int64(rng.Intn(5))-2, int64(rng.Intn(3))).UTC(),
+		u: randBytes(),
+	}
+}
+
+func TestOrder(t *testing.T) {
+	rng := newRand(t)
+	for i := 0; i < 10000; i++ {
+		a, b := randomTuple(rng), randomTuple(rng)
+		ka, kb := a.encode(), b.encode()
+		require.Equal(t, compareTuples(a, b), bytes.Compare(ka, kb), "%v %v", a, b)
+
+		vals, err := Decode(ka)
+		require.NoError(t, err)
+		require.Equal(t, []any{a.i, a.s, a.f, a.b, a.t, a.u}, vals)
+	}
+}
+
+func TestFloatNaN(t *testing.T) {
+	inf := AppendFloat(nil, math.Inf(1), Ascending)
+	nan := AppendFloat(nil, math.NaN(), Ascending)
+	negNaN := AppendFloat(nil, math.Copysign(math.NaN(), -1), Ascending)
+	require.Equal(t, nan, negNaN)
+	require.Equal(t, 1, bytes.Compare(nan, inf))
+	vals, err := Decode(negNaN)
+	require.NoError(t, err)
+	require.True(t, math.IsNaN(vals[0].(float64)))
+}
+
+func TestDecodeField(t *testing.T) {
+	k, err := Encode(uint8(7), Desc(-2.5), Desc(true), Desc([]byte{0, 1}))
+	require.NoError(t, err)
+	var got []any
+	var dirs []Direction
+	for len(k) > 0 {
+		var v any
+		var dir Direction
+		v, dir, k, err = DecodeField(k)
+		require.NoError(t, err)
+		got, dirs = append(got, v), append(dirs, dir)
+	}
+	require.Equal(t, []any{uint64(7), -2.5, true, []byte{0, 1}}, got)
+	require.Equal(t, []Direction{Ascending, Descending, Descending, Descending}, dirs)
+
+	_, err = Encode(struct{}{})
+	require.Error(t, err)
+	for _, bad := range [][]byte{
+		{0x01},
+		{tagInt, 1, 2},
+		{tagString, 'a'},
+		{tagString, 0, 2},
+		{tagTime, 0, 0, 0, 0, 0, 0, 0, 0},
+	} {
+		_, err := Decode(bad)
+		require.True(t, errors.Is(err, ErrInvalidKey), "%v", bad)
+	}
+}
+
+func TestPrefixScan(t *testing.T) {
+	m := btree.MakeMap[[]byte, int](bytes.Compare)
+	for i := 0; i < 10; i++ {
+		for _, s := range []string{"a", "b", "c"} {
+			k, _ := Encode(s, Desc(i))
+			m.Upsert(k, i)
+		}
+	}
+	prefix := AppendString(nil, "b", Ascending)
+	end := PrefixEnd(prefix)
+	var got []int
+	it := m.Iterator()
+	for it.SeekGE(prefix); it.Valid() && bytes.Compare(it.Cur(), end) < 0; it.Next() {
+		require.True(t, bytes.HasPrefix(it.Cur(), prefix))
+		got = append(got, it.Value())
+	}
+	require.Equal(t, []int{9, 8, 7, 6, 5, 4, 3, 2, 1, 0}, got)
+	require.Nil(t, PrefixEnd([]byte{0xff, 0xff}))
+	require.Equal(t, []byte{1, 3}, PrefixEnd([]byte{1, 2, 0xff}))
+}
+
+// newRand returns a source of randomness for a test, logging its seed so
+// that a failure can be reproduced.
+func newRand(t testing.TB) *rand.Rand {
+	t.Helper()
+	seed := time.Now().UnixNano()
+	t.Logf("seed: %d", seed)
+	return rand.New(rand.NewSource(seed))
+}
@@ -0,0 +1,253 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package btree
+
+import (
+	"cmp"
+
+	"github.com/ajwerner/btree/internal/abstract"
+)
+
+// MultiMap is an ordered map from K to V which may associate several values
+// with each key. The values of a key form a group which is ordered either by
+// insertion or by a comparison function for values. Each value is stored as
+// its own entry in the tree, so mutations copy no more than a Map would.
+type MultiMap[K, V any] struct {
+	m   abstract.Map[multiKey[K, V], struct{}, struct{}]
+	cmp func(K, K) int
+	eq  func(V, V) bool
+
+	// seq is the sequence number of the most recently inserted entry.
+	seq uint64
+}
+
+// multiKey is the key of an entry in a MultiMap. Entries with equal keys
+// and values are ordered by seq, which starts at 1. A search key with a
+// non-zero bound orders before (-1) or after (+1) every entry with its key.
+type multiKey[K, V any] struct {
+	k     K
+	v     V
+	seq   uint64
+	bound int8
+}
+
+// MakeMultiMap constructs a new MultiMap with the provided comparison
+// function for keys. The values of each key are ordered by insertion.
+func MakeMultiMap[K any, V comparable](cmpK func(K, K) int, opts ...Option) MultiMap[K, V] {
+	return makeMultiMap(cmpK, nil, func(a, b V) bool { return a == b }, opts)
+}
+
+// MakeSortedMultiMap constructs a new MultiMap with the provided comparison
+// functions for keys and values. The values of each key are ordered by cmpV
+// and equal values by insertion.
+func MakeSortedMultiMap[K, V any](
+	cmpK func(K, K) int, cmpV func(V, V) int, opts ...Option,
+) MultiMap[K, V] {
+	return makeMultiMap(cmpK, cmpV, func(a, b V) bool { return cmpV(a, b) == 0 }, opts)
+}
+
+func makeMultiMap[K, V any](
+	cmpK func(K, K) int, cmpV func(V, V) int, eq func(V, V) bool, opts []Option,
+) MultiMap[K, V] {
+	return MultiMap[K, V]{
+		m: abstract.MakeMap[multiKey[K, V], struct{}, struct{}](func(a, b multiKey[K, V]) int {
+			if c := cmpK(a.k, b.k); c != 0 {
+				return c
+			}
+			if a.bound != 0 || b.bound != 0 {
+				return cmp.Compare(a.bound, b.bound)
+			}
+			if cmpV != nil {
+				if c := cmpV(a.v, b.v); c != 0 {
+					return c
+				}
+			}
+			return cmp.Compare(a.seq, b.seq)
+		}, nil, opts...),
+		cmp: cmpK,
+		eq:  eq,
+	}
+}
+
+// Clone clones the MultiMap, lazily. It does so in constant time.
+func (t *MultiMap[K, V]) Clone() MultiMap[K, V] {
+	c := *t
+	c.m = t.m.Clone()
+	return c
+}
+
+// Reset removes all items from the MultiMap. In doing so, it allows memory
+// held by the MultiMap to be recycled.
+func (t *MultiMap[K, V]) Reset() {
+	t.m.Reset()
+}
+
+// Len returns the number of values in the MultiMap.
+func (t *MultiMap[K, V]) Len() int {
+	return t.m.Len()
+}
+
+// Insert adds the value to the group of the key. The value is added even if
+// the group already contains an equal value.
+func (t *MultiMap[K, V]) Insert(k K, v V) {
+	t.seq++
+	t.m.Upsert(multiKey[K, V]{k: k, v: v, seq: t.seq}, struct{}{})
+}
+
+// GetAll returns the values associated with the key, in order.
+func (t *MultiMap[K, V]) GetAll(k K) []V {
+	var vs []V
+	g := t.Group(k)
+	for g.First(); g.Valid(); g.Next() {
+		vs = append(vs, g.Value())
+	}
+	return vs
+}
+
+// CountOf returns the number of values associated with the key.
+func (t *MultiMap[K, V]) CountOf(k K) int {
+	var n int
+	g := t.Group(k)
+	for g.First(); g.Valid(); g.Next() {
+		n++
+	}
+	return n
+}
+
+// DeleteOne removes the first value in the group of the key which is equal
+// to v. It returns true if such a value existed.
+func (t *MultiMap[K, V]) DeleteOne(k K, v V) (removed bool) {
+	g := t.Group(k)
+	for g.First(); g.Valid(); g.Next() {
+		if t.eq(g.Value(), v) {
+			t.m.Delete(g.it.Cur())
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteAll removes all of the values associated with the key. It returns
+// the number of values removed.
+func (t *MultiMap[K, V]) DeleteAll(k K) (removed int) {
+	it := t.m.MutatingIterator()
+	it.SeekGE(multiKey[K, V]{k: k, bound: -1})
+	for it.Valid() && t.cmp(it.Cur().k, k) == 0 {
+		it.DeleteCurrent()
+		removed++
+	}
+	return removed
+}
+
+// Iterator constructs a new MultiMapIterator for this MultiMap.
+func (t *MultiMap[K, V]) Iterator() MultiMapIterator[K, V] {
+	return MultiMapIterator[K, V]{it: t.m.Iterator()}
+}
+
+// Group constructs a new GroupIterator over the values associated with the
+// key.
+func (t *MultiMap[K, V]) Group(k K) GroupIterator[K, V] {
+	return GroupIterator[K, V]{it: t.m.Iterator(), k: k, cmp: t.cmp}
+}
+
+// MultiMapIterator is an iterator over the entries of a MultiMap. Entries
+// with equal keys are visited in the order of their group.
+type MultiMapIterator[K, V any] struct {
+	it abstract.Iterator[multiKey[K, V], struct{}, struct{}]
+}
+
+// Reset marks the iterator as invalid.
+func (i *MultiMapIterator[K, V]) Reset() { i.it.Reset() }
+
+// First seeks to the first entry in the MultiMap.
+func (i *MultiMapIterator[K, V]) First() { i.it.First() }
+
+// Last seeks to the last entry in the MultiMap.
+func (i *MultiMapIterator[K, V]) Last() { i.it.Last() }
+
+// Next positions the iterator to the following entry.
+func (i *MultiMapIterator[K, V]) Next() { i.it.Next() }
+
+// Prev positions the iterator to the previous entry.
+func (i *MultiMapIterator[K, V]) Prev() { i.it.Prev() }
+
+// Valid returns whether the iterator is positioned at a valid position.
+func (i *MultiMapIterator[K, V]) Valid() bool { return i.it.Valid() }
+
+// SeekGE seeks to the first value of the first key greater-than or equal to
+// the provided key.
+func (i *MultiMapIterator[K, V]) SeekGE(k K) {
+	i.it.SeekGE(multiKey[K, V]{k: k, bound: -1})
+}
+
+// SeekLT seeks to the last value of the last key less-than the provided
+// key.
+func (i *MultiMapIterator[K, V]) SeekLT(k K) {
+	i.it.SeekLT(multiKey[K, V]{k: k, bound: -1})
+}
+
+// Cur returns the key at the iterator's current position. It is illegal to
+// call Cur if the iterator is not valid.
+func (i *MultiMapIterator[K, V]) Cur() K { return i.it.Cur().k }
+
+// Value returns the value at the iterator's current position. It is illegal
+// to call Value if the iterator is not valid.
+func (i *MultiMapIterator[K, V]) Value() V { return i.it.Cur().v }
+
+// GroupIterator is an iterator over the values associated with a single key
+// of a MultiMap.
+type GroupIterator[K, V any] struct {
+	it  abstract.Iterator[multiKey[K, V], struct{}, struct{}]
+	k   K
+	cmp func(K, K) int
+}
+
+// First seeks to the first value of the group.
+func (g *GroupIterator[K, V]) First() {
+	g.it.SeekGE(multiKey[K, V]{k: g.k, bound: -1})
+	g.check()
+}
+
+// Last seeks to the last value of the group.
+func (g *GroupIterator[K, V]) Last() {
+	g.it.SeekLT(multiKey[K, V]{k: g.k, bound: +1})
+	g.check()
+}
+
+// Next positions the iterator to the following value of the group.
+func (g *GroupIterator[K, V]) Next() {
+	g.it.Next()
+	g.check()
+}
+
+// Prev positions the iterator to the previous value of the group.
+func (g *GroupIterator[K, V]) Prev() {
+	g.it.Prev()
+	g.check()
+}
+
+// Valid returns whether the iterator is positioned at a value of the group.
+func (g *GroupIterator[K, V]) Valid() bool { return g.it.Valid() }
+
+// Value returns the value at the iterator's current position. It is illegal
+// to call Value if the iterator is not valid.
+func (g *GroupIterator[K, V]) Value() V { return g.it.Cur().v }
+
+// check invalidates the iterator if it has left the group.
+func (g *GroupIterator[K, V]) check() {
+	if g.it.Valid() && g.cmp(g.it.Cur().k, g.k) != 0 {
+		g.it.Reset()
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package btree
+
+import (
+	"cmp"
+	"slices"
+	"testing"
+)
+
+func TestMultiMap(t *testing.T) {
+	rng := newRand(t)
+	check := func(t *testing.T, m *MultiMap[int, int], ref map[int][]int, sorted bool) {
+		t.Helper()
+		var n int
+		for k, vs := range ref {
+			n += len(vs)
+			if sorted {
+				vs = append([]int(nil), vs...)
+				slices.Sort(vs)
+			}
+			if got := m.GetAll(k); !slices.Equal(got, vs) {
+				t.Fatalf("%d: expected %v, got %v", k, vs, got)
+			}
+			if got := m.CountOf(k); got != len(vs) {
+				t.Fatalf("%d: expected %d values, got %d", k, len(vs), got)
+			}
+		}
+		if m.Len() != n {
+			t.Fatalf("expected %d values, got %d", n, m.Len())
+		}
+		it := m.Iterator()
+		var prev int
+		for it.First(); it.Valid(); it.Next() {
+			if it.Cur() < prev {
+				t.Fatalf("keys out of order: %d after %d", it.Cur(), prev)
+			}
+			prev = it.Cur()
+		}
+	}
+	for _, tc := range []struct {
+		name   string
+		m      MultiMap[int, int]
+		sorted bool
+	}{
+		{"insertion", MakeMultiMap[int, int](cmp.Compare[int]), false},
+		{"sorted", MakeSortedMultiMap[int, int](cmp.Compare[int], cmp.Compare[int]), true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m, ref := tc.m, map[int][]int{}
+			var clone MultiMap[int, int]
+			var cloneRef map[int][]int
+			for i := 0; i < 20000; i++ {
+				k, v := rng.Intn(100), rng.Intn(20)
+				switch r := rng.Intn(20); {
+				case r == 0:
+					if got := m.DeleteAll(k); got != len(ref[k]) {
+						t.Fatalf("expected to delete %d values, deleted %d", len(ref[k]), got)
+					}
+					delete(ref, k)
+				case r < 6:
+					vs := ref[k]
+					j := slices.Index(vs, v)
+					if m.DeleteOne(k, v) != (j >= 0) {
+						t.Fatalf("unexpected result deleting %d from %d", v, k)
+					}
+					if j >= 0 {
+						ref[k] = slices.Delete(vs, j, j+1)
+					}
+				default:
+					m.Insert(k, v)
+					ref[k] = append(ref[k], v)
+				}
+				if i == 10000 {
+					clone = m.Clone()
+					cloneRef = make(map[int][]int, len(ref))
+					for k, vs := range ref {
+						cloneRef[k] = slices.Clone(vs)
+					}
+				}
+			}
+			check(t, &m, ref, tc.sorted)
+			check(t, &clone, cloneRef, tc.sorted)
+			for k, vs := range ref {
+				if len(vs) == 0 {
+					continue
+				}
+				g := m.Group(k)
+				var got []int
+				for g.Last(); g.Valid(); g.Prev() {
+					got = append(got, g.Value())
+				}
+				slices.Reverse(got)
+				if want := m.GetAll(k); !slices.Equal(got, want) {
+					t.Fatalf("%d: expected %v, got %v", k, want, got)
+				}
+			}
+		})
+	}
+}
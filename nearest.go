@@ -0,0 +1,41 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package btree
+
+import (
+	"cmp"
+	"iter"
+
+	"github.com/ajwerner/btree/internal/abstract"
+)
+
+// Number is the set of numeric key types which support distance queries.
+type Number = abstract.Number
+
+// Nearest returns the entries of the Map whose keys are the k closest to x
+// according to dist, ordered by increasing distance. Of keys at equal
+// distances, the smaller comes first. The distance must not decrease as
+// keys move away from x in the order of the Map, as is the case for the
+// absolute difference of numeric keys.
+func Nearest[K, V any, D cmp.Ordered](m *Map[K, V], x K, k int, dist func(a, b K) D) []Entry[K, V] {
+	return abstract.Nearest(&m.Map, x, k, dist)
+}
+
+// WithinDistance returns a sequence of the entries of the Map with keys in
+// [x-d, x+d] in order. If x-d or x+d overflows, the range is unbounded in
+// that direction. The Map must order its keys numerically.
+func WithinDistance[K Number, V any](m *Map[K, V], x, d K) iter.Seq2[K, V] {
+	return abstract.WithinDistance(&m.Map, x, d)
+}
@@ -0,0 +1,77 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package btree
+
+import (
+	"cmp"
+	"slices"
+	"testing"
+)
+
+func TestNearest(t *testing.T) {
+	rng := newRand(t)
+	m := MakeOrderedMap[int, int]()
+	for i := 0; i < 500; i++ {
+		k := rng.Intn(2000) - 1000
+		m.Upsert(k, -k)
+	}
+	var keys []int
+	it := m.Iterator()
+	for it.First(); it.Valid(); it.Next() {
+		keys = append(keys, it.Cur())
+	}
+	dist := func(a, b int) int { return max(a-b, b-a) }
+	for i := 0; i < 200; i++ {
+		x, k := rng.Intn(2400)-1200, rng.Intn(20)
+		want := slices.Clone(keys)
+		slices.SortStableFunc(want, func(a, b int) int { return cmp.Compare(dist(x, a), dist(x, b)) })
+		want = want[:min(k, len(want))]
+		got := Nearest(&m, x, k, dist)
+		if len(got) != len(want) {
+			t.Fatalf("Nearest(%d, %d): expected %d entries, got %d", x, k, len(want), len(got))
+		}
+		for j, e := range got {
+			if e.Key != want[j] || e.Value != -e.Key {
+				t.Fatalf("Nearest(%d, %d): expected %v, got %v", x, k, want, got)
+			}
+		}
+
+		d := rng.Intn(100)
+		var within []int
+		for k := range WithinDistance(&m, x, d) {
+			within = append(within, k)
+		}
+		want = slices.DeleteFunc(slices.Clone(keys), func(k int) bool { return dist(x, k) > d })
+		if !slices.Equal(within, want) {
+			t.Fatalf("WithinDistance(%d, %d): expected %v, got %v", x, d, want, within)
+		}
+	}
+
+	// Bounds which overflow leave the range unbounded.
+	u := MakeOrderedMap[uint8, struct{}]()
+	for _, k := range []uint8{0, 3, 250, 255} {
+		u.Upsert(k, struct{}{})
+	}
+	var got []uint8
+	for k := range WithinDistance(&u, 2, 5) {
+		got = append(got, k)
+	}
+	for k := range WithinDistance(&u, 252, 5) {
+		got = append(got, k)
+	}
+	if want := []uint8{0, 3, 250, 255}; !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package btree
+
+import (
+	"cmp"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestOrderedMap(t *testing.T) {
+	rng := newRand(t)
+	const N = 10000
+	m := MakeOrderedMap[int, int]()
+	ref := MakeMap[int, int](cmp.Compare[int])
+	for i := 0; i < N; i++ {
+		k := rng.Intn(N)
+		if rng.Intn(4) == 0 {
+			_, _, a := m.Delete(k)
+			_, _, b := ref.Delete(k)
+			if a != b {
+				t.Fatalf("expected delete of %d to return %v", k, b)
+			}
+			continue
+		}
+		m.Upsert(k, i)
+		ref.Upsert(k, i)
+	}
+	if m.Len() != ref.Len() {
+		t.Fatalf("expected %d entries, got %d", ref.Len(), m.Len())
+	}
+	checkMatches(t, &m, &ref, seq(-1, N+1))
+	// NaN orders before all other floats, as with cmp.Compare.
+	s := MakeOrderedSet[float64]()
+	for _, f := range []float64{2, math.NaN(), 1, math.Inf(-1), math.NaN()} {
+		s.Upsert(f)
+	}
+	it := s.Iterator()
+	it.First()
+	if s.Len() != 4 || !math.IsNaN(it.Cur()) {
+		t.Fatalf("expected 4 items starting with NaN, got %d", s.Len())
+	}
+}
+
+func BenchmarkOrderedMap(b *testing.B) {
+	const count = 1 << 16
+	keys := rand.Perm(count)
+	for _, tc := range []struct {
+		name string
+		make func() Map[int, int]
+	}{
+		{"func", func() Map[int, int] { return MakeMap[int, int](cmp.Compare[int]) }},
+		{"ordered", func() Map[int, int] { return MakeOrderedMap[int, int]() }},
+	} {
+		m := tc.make()
+		for _, k := range keys {
+			m.Upsert(k, k)
+		}
+		b.Run(tc.name+"/get", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				m.Get(keys[i%count])
+			}
+		})
+		b.Run(tc.name+"/upsert", func(b *testing.B) {
+			for i := 0; i < b.N; i += count {
+				m := tc.make()
+				for _, k := range keys {
+					m.Upsert(k, k)
+				}
+				m.Reset()
+			}
+		})
+	}
+}
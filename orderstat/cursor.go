@@ -0,0 +1,51 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package orderstat
+
+import "github.com/ajwerner/btree/internal/abstract"
+
+// Cursor records where a paginated scan of a Map left off: the last key
+// returned and the rank of the entry which followed it. A scan resumes
+// after the key, so it resumes correctly after the Map is mutated. The zero
+// Cursor refers to the start of the Map. Cursors can be encoded as opaque
+// text with MarshalText, which encodes their keys as JSON.
+type Cursor[K any] = abstract.Cursor[K]
+
+// ErrInvalidCursor is returned when decoding a malformed Cursor, or when
+// encoding a Cursor whose key cannot be represented as JSON.
+var ErrInvalidCursor = abstract.ErrInvalidCursor
+
+// CursorAt returns a Cursor from which a scan starts at the entry with the
+// provided rank. With a page size of limit, page n starts at rank n*limit.
+func CursorAt[K any](rank int) Cursor[K] {
+	return abstract.CursorAt[K](rank)
+}
+
+// Page returns up to limit entries of the Map following the Cursor, in
+// order, along with a Cursor from which to resume the scan. The returned
+// Cursor records the rank of the entry which follows the page, and is done
+// once the scan reaches the end of the Map.
+func Page[K, V any](m *Map[K, V], c Cursor[K], limit int) ([]Entry[K, V], Cursor[K]) {
+	it := m.Iterator()
+	_, hasKey := c.After()
+	if rank, ok := c.Rank(); ok && !hasKey {
+		// SeekNth leaves the iterator invalid if the rank is out of range,
+		// in which case the scan is done.
+		_ = it.SeekNth(rank)
+	} else {
+		abstract.SeekCursor(&it.Iterator, c)
+	}
+	return abstract.FillPage(&it.Iterator, c, limit, it.Rank)
+}
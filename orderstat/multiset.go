@@ -0,0 +1,279 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package orderstat
+
+import (
+	"cmp"
+	"fmt"
+
+	"github.com/ajwerner/btree/internal/abstract"
+)
+
+// MultiSet is a sorted bag in which each distinct item has a count. Like
+// Set, it offers rank operations on its iterator, except that items are
+// weighted by their counts.
+type MultiSet[T any] struct {
+	m abstract.Map[counted[T], struct{}, weight]
+}
+
+// counted is an item of a MultiSet along with its count. Items are ordered
+// by the item alone.
+type counted[T any] struct {
+	item T
+	n    int
+}
+
+// weight is the augmentation of a MultiSet.
+type weight struct {
+	// total is the sum of the counts of the items in the current subtree.
+	total int
+}
+
+// MakeMultiSet constructs a new MultiSet with the provided comparison
+// function.
+func MakeMultiSet[T any](cmp func(T, T) int, opts ...Option) MultiSet[T] {
+	return MultiSet[T]{
+		m: abstract.MakeMap[counted[T], struct{}, weight](
+			func(a, b counted[T]) int { return cmp(a.item, b.item) },
+			weightUpdater[T]{},
+			opts...,
+		),
+	}
+}
+
+// MakeOrderedMultiSet constructs a new MultiSet for items with a natural
+// order.
+func MakeOrderedMultiSet[T cmp.Ordered](opts ...Option) MultiSet[T] {
+	return MakeMultiSet(cmp.Compare[T], opts...)
+}
+
+// Clone clones the MultiSet, lazily. It does so in constant time.
+func (t *MultiSet[T]) Clone() MultiSet[T] {
+	return MultiSet[T]{m: t.m.Clone()}
+}
+
+// Reset removes all items from the MultiSet. In doing so, it allows memory
+// held by the MultiSet to be recycled.
+func (t *MultiSet[T]) Reset() {
+	t.m.Reset()
+}
+
+// Len returns the number of distinct items in the MultiSet.
+func (t *MultiSet[T]) Len() int {
+	return t.m.Len()
+}
+
+// Size returns the number of items in the MultiSet, counting duplicates.
+func (t *MultiSet[T]) Size() int {
+	it := t.m.Iterator()
+	if n := abstract.LowLevel(&it).Node(); n != nil {
+		return n.GetA().total
+	}
+	return 0
+}
+
+// Count returns the count of the item.
+func (t *MultiSet[T]) Count(item T) int {
+	it := t.m.Iterator()
+	it.SeekGE(counted[T]{item: item})
+	if !it.Valid() || it.Compare(it.Cur(), counted[T]{item: item}) != 0 {
+		return 0
+	}
+	return it.Cur().n
+}
+
+// Add adds n copies of the item. It returns the item's new count. Adding a
+// negative number of copies removes them as with Remove.
+func (t *MultiSet[T]) Add(item T, n int) (count int) {
+	if n < 0 {
+		return t.Remove(item, -n)
+	}
+	return t.set(item, n)
+}
+
+// Remove removes up to n copies of the item. It returns the item's new
+// count.
+func (t *MultiSet[T]) Remove(item T, n int) (count int) {
+	if n < 0 {
+		return t.Add(item, -n)
+	}
+	return t.set(item, -n)
+}
+
+// set adds delta to the count of the item, removing the item if its count
+// is no longer positive. The item is removed and reinserted so that the
+// counts of the subtrees containing it are updated.
+func (t *MultiSet[T]) set(item T, delta int) (count int) {
+	if delta == 0 {
+		return t.Count(item)
+	}
+	removed, _, found := t.m.Delete(counted[T]{item: item})
+	if found {
+		count = removed.n
+	}
+	if count += delta; count > 0 {
+		t.m.Upsert(counted[T]{item: item, n: count}, struct{}{})
+	} else {
+		count = 0
+	}
+	return count
+}
+
+// Iterator constructs a new MultiSetIterator for this MultiSet.
+func (t *MultiSet[T]) Iterator() MultiSetIterator[T] {
+	return MultiSetIterator[T]{it: t.m.Iterator()}
+}
+
+// MultiSetIterator is an iterator over the distinct items of a MultiSet.
+type MultiSetIterator[T any] struct {
+	it abstract.Iterator[counted[T], struct{}, weight]
+}
+
+// Reset marks the iterator as invalid.
+func (i *MultiSetIterator[T]) Reset() { i.it.Reset() }
+
+// First seeks to the first item in the MultiSet.
+func (i *MultiSetIterator[T]) First() { i.it.First() }
+
+// Last seeks to the last item in the MultiSet.
+func (i *MultiSetIterator[T]) Last() { i.it.Last() }
+
+// Next positions the iterator to the following item.
+func (i *MultiSetIterator[T]) Next() { i.it.Next() }
+
+// Prev positions the iterator to the previous item.
+func (i *MultiSetIterator[T]) Prev() { i.it.Prev() }
+
+// Valid returns whether the iterator is positioned at a valid position.
+func (i *MultiSetIterator[T]) Valid() bool { return i.it.Valid() }
+
+// SeekGE seeks to the first item greater-than or equal to the provided item.
+func (i *MultiSetIterator[T]) SeekGE(item T) { i.it.SeekGE(counted[T]{item: item}) }
+
+// SeekLT seeks to the last item less-than the provided item.
+func (i *MultiSetIterator[T]) SeekLT(item T) { i.it.SeekLT(counted[T]{item: item}) }
+
+// Cur returns the item at the iterator's current position. It is illegal to
+// call Cur if the iterator is not valid.
+func (i *MultiSetIterator[T]) Cur() T { return i.it.Cur().item }
+
+// Count returns the count of the item at the iterator's current position. It
+// is illegal to call Count if the iterator is not valid.
+func (i *MultiSetIterator[T]) Count() int { return i.it.Cur().n }
+
+// Rank returns the number of items, counting duplicates, which precede the
+// current iterator position. If the iterator is not valid, -1 is returned.
+func (i *MultiSetIterator[T]) Rank() int {
+	if !i.Valid() {
+		return -1
+	}
+	ll := abstract.LowLevel(&i.it)
+	// Sum the weight before the position in each node on the path from the
+	// root, then return to the current position.
+	var buf [8]int16
+	positions := buf[:0]
+	before := weightBefore(ll.Node(), ll.Pos(), !ll.IsLeaf())
+	positions = append(positions, ll.Pos())
+	for ll.Depth() > 0 {
+		ll.Ascend()
+		before += weightBefore(ll.Node(), ll.Pos(), false)
+		positions = append(positions, ll.Pos())
+	}
+	for j := len(positions) - 2; j >= 0; j-- {
+		ll.Descend()
+		ll.SetPos(positions[j])
+	}
+	return before
+}
+
+// weightBefore returns the sum of the counts of the items before pos in n,
+// including those in the child at pos if inclusive is true.
+func weightBefore[T any](
+	n *abstract.Node[counted[T], struct{}, weight], pos int16, inclusive bool,
+) (before int) {
+	for j := int16(0); j < pos; j++ {
+		before += n.GetKey(j).n
+		if c := n.GetChild(j); c != nil {
+			before += c.total
+		}
+	}
+	if c := n.GetChild(pos); inclusive && c != nil {
+		before += c.total
+	}
+	return before
+}
+
+// SeekNth seeks the iterator to the item which holds the nth of the items
+// in the MultiSet (0-indexed), counting duplicates. If there is no such
+// item, it returns ErrOutOfRange and leaves the iterator invalid.
+func (i *MultiSetIterator[T]) SeekNth(nth int) error {
+	i.it.Reset()
+	ll := abstract.LowLevel(&i.it)
+	if ll.Node() == nil || nth < 0 || nth >= ll.Node().GetA().total {
+		return fmt.Errorf("%w: %d", ErrOutOfRange, nth)
+	}
+	for {
+		n := ll.Node()
+		pos := int16(0)
+		for ; pos <= n.Count(); pos++ {
+			ll.SetPos(pos)
+			if c := n.GetChild(pos); c != nil {
+				if nth < c.total {
+					break
+				}
+				nth -= c.total
+			}
+			if pos < n.Count() {
+				w := n.GetKey(pos).n
+				if nth < w {
+					return nil
+				}
+				nth -= w
+			}
+		}
+		if pos > n.Count() {
+			i.it.Reset()
+			return fmt.Errorf("%w: invariant violated", ErrCorrupt)
+		}
+		ll.Descend()
+	}
+}
+
+type weightUpdater[T any] struct{}
+
+func (weightUpdater[T]) Update(
+	n *abstract.Node[counted[T], struct{}, weight],
+	md abstract.UpdateInfo[counted[T], weight],
+) (updated bool) {
+	a := n.GetA()
+	switch md.Action {
+	case abstract.Removal, abstract.Split:
+		a.total -= md.RelevantKey.n
+		if md.ModifiedOther != nil {
+			a.total -= md.ModifiedOther.total
+		}
+		return true
+	case abstract.Insertion:
+		a.total += md.RelevantKey.n
+		if md.ModifiedOther != nil {
+			a.total += md.ModifiedOther.total
+		}
+		return true
+	default:
+		orig := a.total
+		a.total = weightBefore(n, n.Count(), true)
+		return a.total != orig
+	}
+}
@@ -15,6 +15,8 @@
 package orderstat
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
 
 	"github.com/ajwerner/btree/internal/abstract"
@@ -27,9 +29,17 @@ type Map[K, V any] struct {
 }
 
 // MakeMap constructs a new Map with the provided comparison function.
-func MakeMap[K, V any](cmp func(K, K) int) Map[K, V] {
+func MakeMap[K, V any](cmp func(K, K) int, opts ...Option) Map[K, V] {
 	return Map[K, V]{
-		Map: abstract.MakeMap[K, V, aug](cmp, &updater[K, V]{}),
+		Map: abstract.MakeMap[K, V, aug](cmp, &updater[K, V]{}, opts...),
+	}
+}
+
+// MakeOrderedMap constructs a new Map for keys with a natural order. It is
+// equivalent to MakeMap with cmp.Compare but searches faster.
+func MakeOrderedMap[K cmp.Ordered, V any](opts ...Option) Map[K, V] {
+	return Map[K, V]{
+		Map: abstract.MakeOrderedMap[K, V, aug](&updater[K, V]{}, opts...),
 	}
 }
 
@@ -38,6 +48,22 @@ func (t *Map[K, V]) Iterator() Iterator[K, V] {
 	return Iterator[K, V]{Iterator: t.Map.Iterator()}
 }
 
+// SnapshotIterator constructs a new SnapshotIterator over the current
+// version of this Map. The iterator is unaffected by subsequent mutations
+// to the Map and must be closed when no longer in use.
+func (t *Map[K, V]) SnapshotIterator() SnapshotIterator[K, V] {
+	s := t.Map.Snapshot()
+	return SnapshotIterator[K, V]{
+		Iterator: Iterator[K, V]{Iterator: s.Iterator()},
+		s:        s,
+	}
+}
+
+// MutatingIterator constructs a new MutatingIterator for this Map.
+func (t *Map[K, V]) MutatingIterator() MutatingIterator[K, V] {
+	return MutatingIterator[K, V]{Iterator: t.Iterator()}
+}
+
 // Clone clones the Map, lazily. It does so in constant time.
 func (t *Map[K, V]) Clone() Map[K, V] {
 	return Map[K, V]{Map: t.Map.Clone()}
@@ -48,8 +74,14 @@ func (t *Map[K, V]) Clone() Map[K, V] {
 type Set[T any] Map[T, struct{}]
 
 // MakeSet constructs a new Set with the provided comparison function.
-func MakeSet[T any](cmp func(T, T) int) Set[T] {
-	return (Set[T])(MakeMap[T, struct{}](cmp))
+func MakeSet[T any](cmp func(T, T) int, opts ...Option) Set[T] {
+	return (Set[T])(MakeMap[T, struct{}](cmp, opts...))
+}
+
+// MakeOrderedSet constructs a new Set for items with a natural order. It is
+// equivalent to MakeSet with cmp.Compare but searches faster.
+func MakeOrderedSet[T cmp.Ordered](opts ...Option) Set[T] {
+	return (Set[T])(MakeOrderedMap[T, struct{}](opts...))
 }
 
 // Clone clones the Set, lazily. It does so in constant time.
@@ -64,6 +96,22 @@ func (t *Set[T]) Upsert(item T) (replaced T, overwrote bool) {
 	return replaced, overwrote
 }
 
+// UpsertWithHint is like Upsert but uses and updates the provided hint to
+// accelerate insertions of items which are near each other.
+func (t *Set[T]) UpsertWithHint(item T, h *UpsertHint[T, struct{}]) (replaced T, overwrote bool) {
+	replaced, _, overwrote = t.Map.UpsertWithHint(item, struct{}{}, h)
+	return replaced, overwrote
+}
+
+// UpsertBatch upserts each of the provided items. It is most efficient when
+// the items are sorted.
+func (t *Set[T]) UpsertBatch(items []T) {
+	var h UpsertHint[T, struct{}]
+	for _, item := range items {
+		t.UpsertWithHint(item, &h)
+	}
+}
+
 // Delete removes the value with the provided key. It returns true if the
 // item existed in the set.
 func (t *Set[K]) Delete(item K) (removed bool) {
@@ -76,6 +124,35 @@ func (t *Set[K]) Iterator() Iterator[K, struct{}] {
 	return (*Map[K, struct{}])(t).Iterator()
 }
 
+// SnapshotIterator constructs a new SnapshotIterator over the current
+// version of this set.
+func (t *Set[K]) SnapshotIterator() SnapshotIterator[K, struct{}] {
+	return (*Map[K, struct{}])(t).SnapshotIterator()
+}
+
+// Entry is a key-value pair in a Map.
+type Entry[K, V any] = abstract.Entry[K, V]
+
+// UpsertHint accelerates repeated insertions of nearby keys into a Map or
+// Set. The zero value is ready to use.
+type UpsertHint[K, V any] = abstract.UpsertHint[K, V, aug]
+
+// CompactionStats describes the effect of a call to Compact.
+type CompactionStats = abstract.CompactionStats
+
+// Option configures a Map or Set. See btree.WithAllocator.
+type Option = abstract.Option
+
+var (
+	// ErrOutOfRange is returned by SeekNth when the requested rank is not in
+	// the collection.
+	ErrOutOfRange = errors.New("orderstat: rank out of range")
+
+	// ErrCorrupt is returned when the rank information of the tree is found
+	// to be inconsistent.
+	ErrCorrupt = errors.New("orderstat: rank information corrupted")
+)
+
 type aug struct {
 	// children is the number of items rooted at the current subtree.
 	children int
@@ -116,7 +193,8 @@ func (u updater[K, V]) Update(
 		a.children = children
 		return a.children != orig
 	default:
-		panic(fmt.Errorf("unknown action %v", md.Action))
+		// Recompute the augmentation for actions which are not known.
+		return u.Update(n, abstract.UpdateInfo[K, aug]{})
 	}
 }
 
@@ -127,6 +205,49 @@ type Iterator[K, V any] struct {
 	abstract.Iterator[K, V, aug]
 }
 
+// Clone returns an independent Iterator at the same position.
+func (it *Iterator[K, V]) Clone() Iterator[K, V] {
+	return Iterator[K, V]{Iterator: it.Iterator.Clone()}
+}
+
+// Position records the position of an Iterator so that it can later be
+// restored into an Iterator over the same version of the Map.
+type Position[K, V any] = abstract.Position[K, V, aug]
+
+// SnapshotIterator is an Iterator over a pinned version of a Map. It must be
+// closed when no longer in use.
+type SnapshotIterator[K, V any] struct {
+	Iterator[K, V]
+	s *abstract.Snapshot[K, V, aug]
+}
+
+// Close releases the version of the Map pinned by the iterator. The
+// iterator is invalid after Close returns.
+func (it *SnapshotIterator[K, V]) Close() {
+	it.s.Close()
+	it.Reset()
+}
+
+// MutatingIterator is an Iterator which can additionally update or delete
+// the entry at its current position. Mutations keep the rank information
+// consistent and invalidate other iterators over the Map.
+type MutatingIterator[K, V any] struct {
+	Iterator[K, V]
+}
+
+// SetValue replaces the value at the iterator's current position. It is
+// illegal to call SetValue if the iterator is not valid.
+func (it *MutatingIterator[K, V]) SetValue(v V) {
+	lowLevel(&it.Iterator).SetValue(v)
+}
+
+// DeleteCurrent removes the entry at the iterator's current position and
+// positions the iterator at the entry which followed it. It is illegal to
+// call DeleteCurrent if the iterator is not valid.
+func (it *MutatingIterator[K, V]) DeleteCurrent() {
+	lowLevel(&it.Iterator).DeleteCurrent()
+}
+
 // Rank returns the rank of the current iterator position. If the iterator
 // is not valid, -1 is returned.
 func (it *Iterator[K, V]) Rank() int {
@@ -134,38 +255,46 @@ func (it *Iterator[K, V]) Rank() int {
 		return -1
 	}
 	ll := lowLevel(it)
-	// If this is the root, then we want to figure out how many children are
-	// below the current point.
-
-	// Otherwise, we need to go up to the current parent, calculate everything
-	// less and then drop back down to the current node and add everything less.
+	// Count the items in the subtrees to the left of the current position
+	// and the items preceding it in the current node.
 	var before int
-	if ll.Depth() > 0 {
-		pos := ll.Pos()
+	if !ll.IsLeaf() {
+		for i, pos := int16(0), ll.Pos(); i <= pos; i++ {
+			before += ll.Node().GetChild(i).children
+		}
+	}
+	before += int(ll.Pos())
+	// Then do the same in each ancestor up to the root, recording the path
+	// in order to return to the current position.
+	var buf [8]int16
+	positions := append(buf[:0], ll.Pos())
+	for ll.Depth() > 0 {
 		ll.Ascend()
 		for i, parentPos := int16(0), ll.Pos(); i < parentPos; i++ {
 			before += ll.Node().GetChild(i).children
 		}
 		before += int(ll.Pos())
-		ll.Descend()
-		ll.SetPos(pos)
+		positions = append(positions, ll.Pos())
 	}
-	if !ll.IsLeaf() {
-		for i, pos := int16(0), ll.Pos(); i <= pos; i++ {
-			before += ll.Node().GetChild(i).children
-		}
+	for j := len(positions) - 2; j >= 0; j-- {
+		ll.Descend()
+		ll.SetPos(positions[j])
 	}
-	before += int(ll.Pos())
 	return before
 }
 
 // SeekNth seeks the iterator to the nth item in the collection (0-indexed).
-func (it *Iterator[K, V]) SeekNth(nth int) {
+// If there is no such item, it returns ErrOutOfRange and leaves the iterator
+// invalid.
+func (it *Iterator[K, V]) SeekNth(nth int) error {
 	it.Reset()
 	// Reset has bizarre semantics in that it initializes the iterator to
 	// an invalid position (-1) at the root of the tree. IncrementPos moves it
 	// to the first child and item of the
 	ll := lowLevel(it)
+	if ll.Node() == nil || nth < 0 || nth >= ll.Node().GetA().children {
+		return fmt.Errorf("%w: %d", ErrOutOfRange, nth)
+	}
 	ll.IncrementPos()
 	n := 0
 	for n <= nth {
@@ -173,13 +302,17 @@ func (it *Iterator[K, V]) SeekNth(nth int) {
 			// If we're in the leaf, then, by construction, we can find
 			// the relevant position and seek to it in constant time.
 			//
-			// TODO(ajwerner): Add more invariant checking.
-			ll.SetPos(int16(nth - n))
-			return
+			if pos := nth - n; pos < int(ll.Node().Count()) {
+				ll.SetPos(int16(pos))
+				return nil
+			}
+			it.Reset()
+			return fmt.Errorf("%w: leaf too small", ErrCorrupt)
 		}
 		a := ll.Child()
 		if a == nil {
-			onErrorf("failed to visit child")
+			it.Reset()
+			return fmt.Errorf("%w: failed to visit child", ErrCorrupt)
 		}
 		if n+a.children > nth {
 			ll.Descend()
@@ -193,11 +326,14 @@ func (it *Iterator[K, V]) SeekNth(nth int) {
 			n++
 			ll.IncrementPos()
 		case n == nth:
-			return // found it
+			return nil // found it
 		default:
-			onErrorf("invariant violated")
+			it.Reset()
+			return fmt.Errorf("%w: invariant violated", ErrCorrupt)
 		}
 	}
+	it.Reset()
+	return fmt.Errorf("%w: invariant violated", ErrCorrupt)
 }
 
 func lowLevel[K, V any](
@@ -205,7 +341,3 @@ func lowLevel[K, V any](
 ) *abstract.LowLevelIterator[K, V, aug] {
 	return abstract.LowLevel(&it.Iterator)
 }
-
-var onErrorf = func(format string, args ...interface{}) {
-	panic(fmt.Errorf(format, args...))
-}
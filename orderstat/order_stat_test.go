@@ -16,9 +16,12 @@ package orderstat
 
 import (
 	"cmp"
+	"errors"
 	"fmt"
 	"math/rand"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -40,6 +43,25 @@ func TestOrderStatTree(t *testing.T) {
 	require.Equal(t, 4, iter.Cur())
 }
 
+// TestRankDeepTree checks Rank against the true index of every item in a
+// tree tall enough that the path to each leaf passes through several
+// interior nodes.
+func TestRankDeepTree(t *testing.T) {
+	tree := MakeMap[int, int](cmp.Compare[int])
+	for i := 0; tree.Height() < 4; i++ {
+		tree.Upsert(i, i)
+	}
+	iter := tree.Iterator()
+	i := 0
+	for iter.First(); iter.Valid(); iter.Next() {
+		if rank := iter.Rank(); rank != i {
+			require.Equal(t, i, rank, "rank of %d", iter.Cur())
+		}
+		i++
+	}
+	require.Equal(t, tree.Len(), i)
+}
+
 func TestOrderStatNth(t *testing.T) {
 	t.Parallel()
 	tree := MakeSet(cmp.Compare[int])
@@ -88,7 +110,7 @@ func TestOrderStatNth(t *testing.T) {
 
 }
 
-func ExampleBlog() {
+func Example_blog() {
 	s := MakeSet(cmp.Compare[int])
 	for _, i := range rand.Perm(100) {
 		s.Upsert(i)
@@ -102,3 +124,291 @@ func ExampleBlog() {
 	// 100
 	// 90
 }
+
+func TestSnapshotIteratorRank(t *testing.T) {
+	tree := MakeSet(cmp.Compare[int])
+	for i := 0; i < 500; i++ {
+		tree.Upsert(i)
+	}
+	it := tree.SnapshotIterator()
+	defer it.Close()
+	for i := 0; i < 500; i += 3 {
+		tree.Delete(i)
+	}
+	it.SeekNth(250)
+	require.Equal(t, 250, it.Cur())
+	require.Equal(t, 250, it.Rank())
+	require.Equal(t, 333, tree.Len())
+}
+
+func TestMutatingIteratorRank(t *testing.T) {
+	tree := MakeMap[int, string](cmp.Compare[int])
+	for i := 0; i < 1000; i++ {
+		tree.Upsert(i, "")
+	}
+	it := tree.MutatingIterator()
+	for it.First(); it.Valid(); {
+		if it.Cur()%3 == 0 {
+			it.DeleteCurrent()
+			continue
+		}
+		it.SetValue(fmt.Sprint(it.Rank()))
+		it.Next()
+	}
+	require.Equal(t, 666, tree.Len())
+	iter := tree.Iterator()
+	for i := 0; i < tree.Len(); i++ {
+		iter.SeekNth(i)
+		require.Equal(t, fmt.Sprint(i), iter.Value())
+	}
+}
+
+func TestUpsertBatchRank(t *testing.T) {
+	tree := MakeSet(cmp.Compare[int])
+	items := make([]int, 0, 5000)
+	for i := 0; i < 5000; i++ {
+		items = append(items, 2*i)
+	}
+	tree.UpsertBatch(items)
+	var h UpsertHint[int, struct{}]
+	for i := 0; i < 5000; i += 7 {
+		tree.UpsertWithHint(2*i+1, &h)
+	}
+	iter := tree.Iterator()
+	for iter.First(); iter.Valid(); iter.Next() {
+		rank := iter.Rank()
+		iter.SeekNth(rank)
+		require.Equal(t, rank, iter.Rank())
+	}
+	iter.SeekNth(tree.Len() - 1)
+	require.Equal(t, 9998, iter.Cur())
+}
+
+func BenchmarkUpsertAppend(b *testing.B) {
+	const count = 1 << 16
+	items := make([]int, count)
+	for i := range items {
+		items[i] = i
+	}
+	b.Run("upsert", func(b *testing.B) {
+		for i := 0; i < b.N; i += count {
+			s := MakeSet(cmp.Compare[int])
+			for _, item := range items {
+				s.Upsert(item)
+			}
+			s.Reset()
+		}
+	})
+	b.Run("batch", func(b *testing.B) {
+		for i := 0; i < b.N; i += count {
+			s := MakeSet(cmp.Compare[int])
+			s.UpsertBatch(items)
+			s.Reset()
+		}
+	})
+}
+
+func TestCompactRank(t *testing.T) {
+	tree := MakeSet(cmp.Compare[int])
+	for i := 0; i < 20000; i++ {
+		tree.Upsert(i)
+	}
+	for i := 0; i < 20000; i++ {
+		if i%10 != 0 {
+			tree.Delete(i)
+		}
+	}
+	stats := tree.Compact(1)
+	require.Less(t, stats.NodesAfter, stats.NodesBefore)
+	iter := tree.Iterator()
+	for i := 0; i < tree.Len(); i++ {
+		iter.SeekNth(i)
+		require.Equal(t, 10*i, iter.Cur())
+		require.Equal(t, i, iter.Rank())
+	}
+}
+
+func TestOrderedSetRank(t *testing.T) {
+	rng := newRand(t)
+	tree := MakeOrderedSet[int]()
+	for _, i := range rng.Perm(5000) {
+		tree.Upsert(3 * i)
+	}
+	iter := tree.Iterator()
+	for i := 0; i < 5000; i++ {
+		iter.SeekGE(3*i - 1)
+		require.Equal(t, 3*i, iter.Cur())
+		require.Equal(t, i, iter.Rank())
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	m := MakeOrderedMap[string, int]()
+	words := []string{"", "a", "ab", "abc", "abd", "ac", "b", "b\xff", "b\xff\xff", "c"}
+	for i, w := range words {
+		m.Upsert(w, i)
+	}
+	for i := 0; i < 2000; i++ {
+		m.Upsert(fmt.Sprintf("z%04d", i), i)
+	}
+	for _, p := range []string{"", "a", "ab", "abc", "abe", "b", "b\xff", "z", "z01", "z1999", "zz"} {
+		var want []string
+		for _, w := range words {
+			if len(w) >= len(p) && w[:len(p)] == p {
+				want = append(want, w)
+			}
+		}
+		for i := 0; i < 2000; i++ {
+			if w := fmt.Sprintf("z%04d", i); len(w) >= len(p) && w[:len(p)] == p {
+				want = append(want, w)
+			}
+		}
+		require.Equal(t, len(want), CountPrefix(&m, p), p)
+		var got []string
+		it := ScanPrefix(&m, p)
+		for k := range it.All() {
+			got = append(got, k)
+		}
+		require.Equal(t, want, got, p)
+		if it.Last(); len(want) > 0 {
+			require.Equal(t, want[len(want)-1], it.Cur(), p)
+		} else {
+			require.False(t, it.Valid())
+		}
+	}
+}
+
+func TestNearest(t *testing.T) {
+	m := MakeOrderedMap[float64, int]()
+	for i := 0; i < 100; i++ {
+		m.Upsert(float64(i)/4, i)
+	}
+	dist := func(a, b float64) float64 { return max(a-b, b-a) }
+	var got []float64
+	for _, e := range Nearest(&m, 10.3, 4, dist) {
+		got = append(got, e.Key)
+	}
+	require.Equal(t, []float64{10.25, 10.5, 10, 10.75}, got)
+	require.Len(t, Nearest(&m, -5, 200, dist), 100)
+
+	got = got[:0]
+	for k, v := range WithinDistance(&m, 20, 0.5) {
+		require.Equal(t, int(k*4), v)
+		got = append(got, k)
+	}
+	require.Equal(t, []float64{19.5, 19.75, 20, 20.25, 20.5}, got)
+}
+
+func TestPage(t *testing.T) {
+	m := MakeOrderedMap[int, int]()
+	for i := 0; i < 1000; i++ {
+		m.Upsert(i*10, i)
+	}
+	// Jump directly to the fourth page of 25 entries.
+	entries, c := Page(&m, CursorAt[int](3*25), 25)
+	require.Len(t, entries, 25)
+	require.Equal(t, 750, entries[0].Key)
+	rank, ok := c.Rank()
+	require.True(t, ok)
+	require.Equal(t, 100, rank)
+
+	text, err := c.MarshalText()
+	require.NoError(t, err)
+	var decoded Cursor[int]
+	require.NoError(t, decoded.UnmarshalText(text))
+	require.Equal(t, c, decoded)
+
+	// Entries inserted before the cursor shift the ranks of later pages but
+	// not the entries they return.
+	m.Upsert(5, -1)
+	entries, c = Page(&m, decoded, 25)
+	require.Equal(t, 1000, entries[0].Key)
+	rank, _ = c.Rank()
+	require.Equal(t, 126, rank)
+
+	entries, c = Page(&m, CursorAt[int](990), 25)
+	require.Len(t, entries, 11)
+	require.True(t, c.Done())
+	entries, c = Page(&m, CursorAt[int](5000), 25)
+	require.Empty(t, entries)
+	require.True(t, c.Done())
+	require.True(t, errors.Is(decoded.UnmarshalText([]byte("eyJyIjotMX0")), ErrInvalidCursor))
+}
+
+func TestSeekNthOutOfRange(t *testing.T) {
+	tree := MakeOrderedSet[int]()
+	it := tree.Iterator()
+	require.True(t, errors.Is(it.SeekNth(0), ErrOutOfRange))
+	require.False(t, it.Valid())
+	for i := 0; i < 1000; i++ {
+		tree.Upsert(i)
+	}
+	it = tree.Iterator()
+	for _, nth := range []int{-1, 1000, 5000} {
+		require.True(t, errors.Is(it.SeekNth(nth), ErrOutOfRange))
+		require.False(t, it.Valid())
+	}
+	require.NoError(t, it.SeekNth(999))
+	require.Equal(t, 999, it.Cur())
+}
+
+func TestMultiSet(t *testing.T) {
+	rng := newRand(t)
+	const N = 2000
+	s := MakeOrderedMultiSet[int]()
+	ref := make([]int, N)
+	var clone MultiSet[int]
+	var cloneRef []int
+	for i := 0; i < 20000; i++ {
+		x, n := rng.Intn(N), rng.Intn(5)
+		var count int
+		if rng.Intn(3) == 0 {
+			count = s.Remove(x, n)
+			ref[x] = max(ref[x]-n, 0)
+		} else {
+			count = s.Add(x, n)
+			ref[x] += n
+		}
+		require.Equal(t, ref[x], count)
+		if i == 10000 {
+			clone, cloneRef = s.Clone(), slices.Clone(ref)
+		}
+	}
+	check := func(s *MultiSet[int], ref []int) {
+		var size, distinct int
+		for x, c := range ref {
+			require.Equal(t, c, s.Count(x))
+			size += c
+			if c > 0 {
+				distinct++
+			}
+		}
+		require.Equal(t, size, s.Size())
+		require.Equal(t, distinct, s.Len())
+		it := s.Iterator()
+		var rank int
+		for it.First(); it.Valid(); it.Next() {
+			require.Equal(t, rank, it.Rank())
+			c := ref[it.Cur()]
+			require.Equal(t, c, it.Count())
+			for _, nth := range []int{rank, rank + c - 1} {
+				seek := s.Iterator()
+				require.NoError(t, seek.SeekNth(nth))
+				require.Equal(t, it.Cur(), seek.Cur())
+			}
+			rank += c
+		}
+		require.True(t, errors.Is(it.SeekNth(size), ErrOutOfRange))
+	}
+	check(&s, ref)
+	check(&clone, cloneRef)
+}
+
+// newRand returns a source of randomness for a test, logging its seed so
+// that a failure can be reproduced.
+func newRand(t testing.TB) *rand.Rand {
+	t.Helper()
+	seed := time.Now().UnixNano()
+	t.Logf("seed: %d", seed)
+	return rand.New(rand.NewSource(seed))
+}
@@ -0,0 +1,153 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package pq provides a priority queue whose elements can be reprioritized
+// or removed through the handles returned when they are pushed.
+package pq
+
+import (
+	"cmp"
+
+	"github.com/ajwerner/btree"
+)
+
+// Queue is a priority queue of values of type T with priorities of type P.
+// The element with the lowest priority is at the front of the queue, and
+// elements with equal priorities are ordered by the time at which they were
+// pushed.
+type Queue[P, T any] struct {
+	byPriority btree.Map[entry[P], T]
+	byHandle   btree.Map[uint64, P]
+
+	// seq is the sequence number of the most recently pushed element.
+	seq uint64
+}
+
+// entry is the key of an element in the queue.
+type entry[P any] struct {
+	p   P
+	seq uint64
+}
+
+// Handle refers to an element of a Queue. The zero Handle refers to no
+// element.
+type Handle struct {
+	seq uint64
+}
+
+// Option configures a Queue. See btree.WithAllocator.
+type Option = btree.Option
+
+// MakeQueue constructs a new Queue with the provided comparison function for
+// priorities.
+func MakeQueue[P, T any](cmpP func(P, P) int, opts ...Option) Queue[P, T] {
+	return Queue[P, T]{
+		byPriority: btree.MakeMap[entry[P], T](func(a, b entry[P]) int {
+			if c := cmpP(a.p, b.p); c != 0 {
+				return c
+			}
+			return cmp.Compare(a.seq, b.seq)
+		}, opts...),
+		byHandle: btree.MakeOrderedMap[uint64, P](opts...),
+	}
+}
+
+// MakeOrderedQueue constructs a new Queue for priorities with a natural
+// order.
+func MakeOrderedQueue[P cmp.Ordered, T any](opts ...Option) Queue[P, T] {
+	return MakeQueue[P, T](cmp.Compare[P], opts...)
+}
+
+// Clone clones the Queue, lazily. It does so in constant time. Handles
+// returned by the Queue refer to the same elements in the clone.
+func (q *Queue[P, T]) Clone() Queue[P, T] {
+	return Queue[P, T]{
+		byPriority: q.byPriority.Clone(),
+		byHandle:   q.byHandle.Clone(),
+		seq:        q.seq,
+	}
+}
+
+// Reset removes all elements from the Queue. In doing so, it allows memory
+// held by the Queue to be recycled.
+func (q *Queue[P, T]) Reset() {
+	q.byPriority.Reset()
+	q.byHandle.Reset()
+}
+
+// Len returns the number of elements in the Queue.
+func (q *Queue[P, T]) Len() int {
+	return q.byPriority.Len()
+}
+
+// Push adds the value to the Queue with the provided priority. It returns a
+// Handle to the new element.
+func (q *Queue[P, T]) Push(p P, v T) Handle {
+	q.seq++
+	q.byPriority.Upsert(entry[P]{p: p, seq: q.seq}, v)
+	q.byHandle.Upsert(q.seq, p)
+	return Handle{seq: q.seq}
+}
+
+// Peek returns the element at the front of the Queue without removing it.
+func (q *Queue[P, T]) Peek() (h Handle, p P, v T, ok bool) {
+	it := q.byPriority.Iterator()
+	if it.First(); !it.Valid() {
+		return h, p, v, false
+	}
+	return Handle{seq: it.Cur().seq}, it.Cur().p, it.Value(), true
+}
+
+// Pop removes and returns the element at the front of the Queue.
+func (q *Queue[P, T]) Pop() (p P, v T, ok bool) {
+	var h Handle
+	if h, p, v, ok = q.Peek(); ok {
+		q.byPriority.Delete(entry[P]{p: p, seq: h.seq})
+		q.byHandle.Delete(h.seq)
+	}
+	return p, v, ok
+}
+
+// Get returns the priority and value of the element referred to by the
+// Handle, if it is still in the Queue.
+func (q *Queue[P, T]) Get(h Handle) (p P, v T, ok bool) {
+	if p, ok = q.byHandle.Get(h.seq); ok {
+		v, _ = q.byPriority.Get(entry[P]{p: p, seq: h.seq})
+	}
+	return p, v, ok
+}
+
+// Update changes the priority of the element referred to by the Handle. The
+// element keeps its place relative to other elements pushed with equal
+// priorities. It returns false if the element is no longer in the Queue.
+func (q *Queue[P, T]) Update(h Handle, p P) (ok bool) {
+	old, ok := q.byHandle.Get(h.seq)
+	if !ok {
+		return false
+	}
+	_, v, _ := q.byPriority.Delete(entry[P]{p: old, seq: h.seq})
+	q.byPriority.Upsert(entry[P]{p: p, seq: h.seq}, v)
+	q.byHandle.Upsert(h.seq, p)
+	return true
+}
+
+// Remove removes the element referred to by the Handle. It returns the
+// element's value if it was still in the Queue.
+func (q *Queue[P, T]) Remove(h Handle) (v T, ok bool) {
+	_, p, ok := q.byHandle.Delete(h.seq)
+	if ok {
+		_, v, _ = q.byPriority.Delete(entry[P]{p: p, seq: h.seq})
+	}
+	return v, ok
+}
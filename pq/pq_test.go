@@ -0,0 +1,119 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package pq
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueue(t *testing.T) {
+	q := MakeOrderedQueue[int, string]()
+	a := q.Push(2, "a")
+	q.Push(1, "b")
+	c := q.Push(2, "c")
+	d := q.Push(3, "d")
+	require.Equal(t, 4, q.Len())
+
+	h, p, v, ok := q.Peek()
+	require.True(t, ok)
+	require.Equal(t, 1, p)
+	require.Equal(t, "b", v)
+	_, _, ok = q.Get(h)
+	require.True(t, ok)
+
+	// Moving d to priority 2 places it after a and c, which were pushed
+	// before it.
+	require.True(t, q.Update(d, 2))
+	v, ok = q.Remove(a)
+	require.True(t, ok)
+	require.Equal(t, "a", v)
+	_, ok = q.Remove(a)
+	require.False(t, ok)
+	require.False(t, q.Update(a, 0))
+
+	clone := q.Clone()
+	var popped []string
+	for {
+		_, v, ok := q.Pop()
+		if !ok {
+			break
+		}
+		popped = append(popped, v)
+	}
+	require.Equal(t, []string{"b", "c", "d"}, popped)
+	require.Equal(t, 0, q.Len())
+
+	// The clone is unaffected and its handles remain valid.
+	require.Equal(t, 3, clone.Len())
+	require.True(t, clone.Update(c, 0))
+	_, v, _ = clone.Pop()
+	require.Equal(t, "c", v)
+}
+
+func TestQueueRandomized(t *testing.T) {
+	type elem struct {
+		h   Handle
+		p   int
+		seq int
+	}
+	less := func(a, b elem) int {
+		if a.p != b.p {
+			return a.p - b.p
+		}
+		return a.seq - b.seq
+	}
+	rng := newRand(t)
+	q := MakeOrderedQueue[int, int]()
+	var live []elem
+	for i := 0; i < 10000; i++ {
+		switch op := rng.Intn(4); {
+		case op == 0 || len(live) == 0:
+			p := rng.Intn(100)
+			live = append(live, elem{h: q.Push(p, i), p: p, seq: i})
+		case op == 1:
+			slices.SortFunc(live, less)
+			p, v, ok := q.Pop()
+			require.True(t, ok)
+			require.Equal(t, live[0].p, p)
+			require.Equal(t, live[0].seq, v)
+			live = live[1:]
+		case op == 2:
+			j := rng.Intn(len(live))
+			live[j].p = rng.Intn(100)
+			require.True(t, q.Update(live[j].h, live[j].p))
+		case op == 3:
+			j := rng.Intn(len(live))
+			v, ok := q.Remove(live[j].h)
+			require.True(t, ok)
+			require.Equal(t, live[j].seq, v)
+			live = append(live[:j], live[j+1:]...)
+		}
+		require.Equal(t, len(live), q.Len())
+	}
+}
+
+// newRand returns a source of randomness for a test, logging its seed so
+// that a failure can be reproduced.
+func newRand(t testing.TB) *rand.Rand {
+	t.Helper()
+	seed := time.Now().UnixNano()
+	t.Logf("seed: %d", seed)
+	return rand.New(rand.NewSource(seed))
+}
@@ -0,0 +1,31 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package btree
+
+import "github.com/ajwerner/btree/internal/abstract"
+
+// Bytes is the set of key types which support prefix scans.
+type Bytes = abstract.Bytes
+
+// PrefixIterator is an iterator over the entries of a Map whose keys begin
+// with a prefix.
+type PrefixIterator[K Bytes, V any] = abstract.PrefixIterator[K, V, struct{}]
+
+// ScanPrefix constructs a new PrefixIterator over the entries of the Map
+// whose keys begin with the prefix. The Map must order its keys bytewise,
+// as with cmp.Compare or bytes.Compare.
+func ScanPrefix[K Bytes, V any](m *Map[K, V], prefix K) PrefixIterator[K, V] {
+	return abstract.ScanPrefix(&m.Map, prefix)
+}
@@ -0,0 +1,67 @@
+// Copyright 2021 Andrew Werner.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package btree
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestScanPrefix(t *testing.T) {
+	m := MakeMap[[]byte, int](bytes.Compare)
+	for i := 0; i < 1000; i++ {
+		m.Upsert([]byte(fmt.Sprint(i)), i)
+	}
+	m.Upsert([]byte{0xff}, -1)
+	m.Upsert([]byte{0xff, 0xff}, -2)
+	for _, tc := range []struct {
+		prefix string
+		want   []int
+	}{
+		{"99", []int{99, 990, 991, 992, 993, 994, 995, 996, 997, 998, 999}},
+		{"50", append([]int{50}, seq(500, 510)...)},
+		{"123", []int{123}},
+		{"1234", nil},
+		{"\xff", []int{-1, -2}},
+	} {
+		var got []int
+		it := ScanPrefix(&m, []byte(tc.prefix))
+		for _, v := range it.All() {
+			got = append(got, v)
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Fatalf("prefix %q: expected %v, got %v", tc.prefix, tc.want, got)
+		}
+		// Iterating in reverse visits the same entries.
+		got = got[:0]
+		for it.Last(); it.Valid(); it.Prev() {
+			got = append(got, it.Value())
+		}
+		slices.Reverse(got)
+		if !slices.Equal(got, tc.want) {
+			t.Fatalf("prefix %q: expected %v in reverse, got %v", tc.prefix, tc.want, got)
+		}
+	}
+	it := ScanPrefix(&m, nil)
+	n := 0
+	for range it.All() {
+		n++
+	}
+	if n != m.Len() {
+		t.Fatalf("expected the empty prefix to scan %d entries, got %d", m.Len(), n)
+	}
+}